@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+)
+
+// builtins are the functions predefined by text/template and html/template.
+var builtins = map[string]bool{
+	"and": true, "call": true, "html": true, "index": true, "slice": true,
+	"js": true, "len": true, "not": true, "or": true, "print": true,
+	"printf": true, "println": true, "urlquery": true, "eq": true, "ge": true,
+	"gt": true, "le": true, "lt": true, "ne": true,
+}
+
+type config struct {
+	dir        string
+	includes   []string
+	entryPoint string
+	// funcs is the set of functions declared in the FuncMap manifest. If nil,
+	// functions are not checked.
+	funcs map[string]bool
+}
+
+// templateFile is a single parsed template file.
+type templateFile struct {
+	path    string
+	include bool
+	trees   map[string]*parse.Tree
+}
+
+// readManifest reads a FuncMap manifest file.
+func readManifest(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read FuncMap manifest")
+	}
+	defer f.Close()
+	funcs := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		funcs[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read FuncMap manifest")
+	}
+	return funcs, nil
+}
+
+// check loads the templates described by conf, and returns a list of the
+// problems found.
+func check(conf config) ([]string, error) {
+	var problems []string
+	var files []*templateFile
+	for _, libPath := range conf.includes {
+		paths, err := filepath.Glob(libPath + "/*")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid include path '%s'", libPath)
+		}
+		for _, path := range paths {
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				continue
+			}
+			files = append(files, &templateFile{path: path, include: true})
+		}
+	}
+	pages, err := templatePaths(conf.dir, conf.includes)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range pages {
+		files = append(files, &templateFile{path: path})
+	}
+
+	// defined maps template names to the files which define them.
+	defined := make(map[string][]*templateFile)
+	for _, file := range files {
+		trees, err := parseFile(file.path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", file.path, err))
+			continue
+		}
+		file.trees = trees
+		for name := range trees {
+			defined[name] = append(defined[name], file)
+		}
+	}
+
+	isDefined := func(name string, file *templateFile) bool {
+		for _, f := range defined[name] {
+			if f == file || f.include || file.include {
+				return true
+			}
+		}
+		return false
+	}
+	// referenced is the set of template names referenced from each file.
+	referenced := make(map[*templateFile]map[string]bool)
+	for _, file := range files {
+		referenced[file] = make(map[string]bool)
+		for _, name := range sortedNames(file.trees) {
+			tree := file.trees[name]
+			walk(tree.Root, func(node parse.Node) {
+				switch n := node.(type) {
+				case *parse.TemplateNode:
+					referenced[file][n.Name] = true
+					if !isDefined(n.Name, file) {
+						problems = append(problems, fmt.Sprintf("%s: undefined template %q", location(file, tree, n), n.Name))
+					}
+				case *parse.IdentifierNode:
+					if conf.funcs != nil && !builtins[n.Ident] && !conf.funcs[n.Ident] {
+						problems = append(problems, fmt.Sprintf("%s: function %q not in FuncMap manifest", location(file, tree, n), n.Ident))
+					}
+				}
+			})
+		}
+	}
+
+	for _, file := range files {
+		if !file.include || file.trees == nil {
+			continue
+		}
+		if !isUsed(file, files, referenced, conf.entryPoint) {
+			problems = append(problems, fmt.Sprintf("%s: unused include", file.path))
+		}
+	}
+	return problems, nil
+}
+
+// isUsed returns true if any template defined in the include file is the entry
+// point, or is referenced from another file.
+func isUsed(file *templateFile, files []*templateFile, referenced map[*templateFile]map[string]bool, entryPoint string) bool {
+	for name := range file.trees {
+		if name == entryPoint {
+			return true
+		}
+		for _, other := range files {
+			if other != file && referenced[other][name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// templatePaths returns the paths of all template files found in dir and its
+// subdirectories, excluding include paths.
+func templatePaths(dir string, includes []string) ([]string, error) {
+	skip := make(map[string]bool, len(includes))
+	for _, libPath := range includes {
+		skip[filepath.Clean(libPath)] = true
+	}
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && skip[filepath.Clean(path)] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, errors.Wrapf(err, "failed to read template dir '%s'", dir)
+}
+
+// parseFile parses the template file at path, named after its base name as
+// template.ParseFiles does, and returns all of the templates it defines.
+func parseFile(path string) (map[string]*parse.Tree, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	_, err = tree.Parse(string(text), "", "", trees)
+	return trees, err
+}
+
+// location returns the position of node, in the form path:line:col.
+func location(file *templateFile, tree *parse.Tree, node parse.Node) string {
+	loc, _ := tree.ErrorContext(node)
+	return file.path + strings.TrimPrefix(loc, tree.ParseName)
+}
+
+func sortedNames(trees map[string]*parse.Tree) []string {
+	names := make([]string, 0, len(trees))
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// walk calls fn for node, and each of its descendants.
+func walk(node parse.Node, fn func(parse.Node)) {
+	if node == nil {
+		return
+	}
+	fn(node)
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walk(child, fn)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walk(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walk(arg, fn)
+		}
+	case *parse.ChainNode:
+		walk(n.Node, fn)
+	case *parse.TemplateNode:
+		walk(n.Pipe, fn)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	walk(n.Pipe, fn)
+	walk(n.List, fn)
+	walk(n.ElseList, fn)
+}
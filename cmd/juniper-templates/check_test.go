@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flimzy/diff"
+	"github.com/flimzy/testy"
+)
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected map[string]bool
+		err      string
+	}{
+		{
+			name: "not found",
+			path: "testdata/missing.txt",
+			err:  "failed to read FuncMap manifest: open testdata/missing.txt: no such file or directory",
+		},
+		{
+			name:     "comments and blank lines",
+			path:     "testdata/bad/funcs.txt",
+			expected: map[string]bool{"lower": true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := readManifest(test.path)
+			testy.Error(t, test.err, err)
+			if d := diff.Interface(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     config
+		expected []string
+		err      string
+	}{
+		{
+			name: "missing dir",
+			conf: config{dir: "testdata/missing"},
+			err:  "failed to read template dir 'testdata/missing': lstat testdata/missing: no such file or directory",
+		},
+		{
+			name: "no problems",
+			conf: config{
+				dir:        "testdata/good",
+				includes:   []string{"testdata/good/lib"},
+				entryPoint: "base.tmpl",
+				funcs:      map[string]bool{"upper": true},
+			},
+		},
+		{
+			name: "unused entry point",
+			conf: config{
+				dir:      "testdata/good",
+				includes: []string{"testdata/good/lib"},
+			},
+			expected: []string{"testdata/good/lib/base.tmpl: unused include"},
+		},
+		{
+			name: "funcs not checked",
+			conf: config{
+				dir:        "testdata/bad",
+				includes:   []string{"testdata/bad/lib"},
+				entryPoint: "base.tmpl",
+			},
+			expected: []string{
+				"testdata/bad/broken.tmpl: template: broken.tmpl:2: unexpected EOF",
+				`testdata/bad/page.tmpl:1:34: undefined template "missing"`,
+				"testdata/bad/lib/unused.tmpl: unused include",
+			},
+		},
+		{
+			name: "all problems",
+			conf: config{
+				dir:        "testdata/bad",
+				includes:   []string{"testdata/bad/lib"},
+				entryPoint: "base.tmpl",
+				funcs:      map[string]bool{"lower": true},
+			},
+			expected: []string{
+				"testdata/bad/broken.tmpl: template: broken.tmpl:2: unexpected EOF",
+				`testdata/bad/page.tmpl:1:34: undefined template "missing"`,
+				`testdata/bad/page.tmpl:1:69: function "upper" not in FuncMap manifest`,
+				"testdata/bad/lib/unused.tmpl: unused include",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := check(test.conf)
+			testy.Error(t, test.err, err)
+			if d := diff.Interface(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+	}{
+		{
+			name:   "no args",
+			status: 2,
+		},
+		{
+			name:   "success",
+			args:   []string{"-dir", "testdata/good", "-include", "testdata/good/lib", "-entry", "base.tmpl", "-funcs", "testdata/good/funcs.txt"},
+			status: 0,
+		},
+		{
+			name:   "missing manifest",
+			args:   []string{"-dir", "testdata/good", "-funcs", "testdata/missing.txt"},
+			status: 2,
+		},
+		{
+			name:   "problems",
+			args:   []string{"-dir", "testdata/good", "-include", "testdata/good/lib"},
+			status: 1,
+			stdout: "testdata/good/lib/base.tmpl: unused include\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			status := run(test.args, stdout, stderr)
+			if status != test.status {
+				t.Errorf("Unexpected exit status: %d\n%s", status, stderr)
+			}
+			if d := diff.Text(test.stdout, stdout.String()); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
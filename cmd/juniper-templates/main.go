@@ -0,0 +1,83 @@
+// Command juniper-templates checks a tree of view templates for common errors.
+// Templates are loaded the same way the view middleware loads them: each file
+// in the template directory is parsed along with every file in each include
+// path. The following problems are reported:
+//
+//  - Template parse errors
+//  - References to undefined templates in {{template}} calls
+//  - Include files which define no template referenced by any other template
+//  - Functions which are neither built in, nor listed in the FuncMap manifest
+//
+// The FuncMap manifest is a plain text file with one function name per line.
+// Blank lines, and lines beginning with '#', are ignored. If no manifest is
+// provided, function names are not checked.
+//
+// The exit status is 0 if no problems were found, 1 if problems were found, and
+// 2 for usage errors, which makes the command suitable for use in pre-commit
+// hooks and CI checks.
+//
+//  juniper-templates -dir templates -include templates/lib -funcs funcs.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stringList is a flag.Value which may be passed multiple times.
+type stringList []string
+
+var _ flag.Value = &stringList{}
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("juniper-templates", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var conf config
+	flags.StringVar(&conf.dir, "dir", "", "The template directory (required)")
+	flags.Var((*stringList)(&conf.includes), "include", "An include path; may be repeated")
+	flags.StringVar(&conf.entryPoint, "entry", "", "The entry point template, if any")
+	funcsFile := flags.String("funcs", "", "A FuncMap manifest file, listing one function name per line")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if conf.dir == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	if *funcsFile != "" {
+		funcs, err := readManifest(*funcsFile)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		conf.funcs = funcs
+	}
+	problems, err := check(conf)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	for _, p := range problems {
+		fmt.Fprintln(stdout, p)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
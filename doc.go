@@ -1,2 +1,4 @@
 // Package juniper provides some general-purpose HTTP middlewares and other helpers.
+//
+// Juniper requires Go 1.20 or later.
 package juniper
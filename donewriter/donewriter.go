@@ -22,7 +22,10 @@
 package donewriter
 
 import (
+	"bufio"
 	"errors"
+	"io"
+	"net"
 	"net/http"
 )
 
@@ -39,9 +42,112 @@ type doneWriter struct {
 
 var _ http.ResponseWriter = &doneWriter{}
 
-// New returns a new DoneWriter instance which wraps rw.
+// New returns a new DoneWriter instance which wraps rw. The returned writer
+// implements each of http.Flusher, http.Hijacker, http.Pusher and
+// io.ReaderFrom only if rw does. Flushing, hijacking or calling ReadFrom marks
+// the writer as done.
 func New(rw http.ResponseWriter) DoneWriter {
-	return &doneWriter{ResponseWriter: rw}
+	w := &doneWriter{ResponseWriter: rw}
+	_, isFlusher := rw.(http.Flusher)
+	_, isHijacker := rw.(http.Hijacker)
+	_, isPusher := rw.(http.Pusher)
+	_, isReaderFrom := rw.(io.ReaderFrom)
+	f, h, p, rf := flusher{w}, hijacker{w}, pusher{w}, readerFrom{w}
+	switch {
+	case isFlusher && isHijacker && isPusher && isReaderFrom:
+		return struct {
+			*doneWriter
+			flusher
+			hijacker
+			pusher
+			readerFrom
+		}{w, f, h, p, rf}
+	case isFlusher && isHijacker && isPusher:
+		return struct {
+			*doneWriter
+			flusher
+			hijacker
+			pusher
+		}{w, f, h, p}
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			*doneWriter
+			flusher
+			hijacker
+			readerFrom
+		}{w, f, h, rf}
+	case isFlusher && isPusher && isReaderFrom:
+		return struct {
+			*doneWriter
+			flusher
+			pusher
+			readerFrom
+		}{w, f, p, rf}
+	case isHijacker && isPusher && isReaderFrom:
+		return struct {
+			*doneWriter
+			hijacker
+			pusher
+			readerFrom
+		}{w, h, p, rf}
+	case isFlusher && isHijacker:
+		return struct {
+			*doneWriter
+			flusher
+			hijacker
+		}{w, f, h}
+	case isFlusher && isPusher:
+		return struct {
+			*doneWriter
+			flusher
+			pusher
+		}{w, f, p}
+	case isFlusher && isReaderFrom:
+		return struct {
+			*doneWriter
+			flusher
+			readerFrom
+		}{w, f, rf}
+	case isHijacker && isPusher:
+		return struct {
+			*doneWriter
+			hijacker
+			pusher
+		}{w, h, p}
+	case isHijacker && isReaderFrom:
+		return struct {
+			*doneWriter
+			hijacker
+			readerFrom
+		}{w, h, rf}
+	case isPusher && isReaderFrom:
+		return struct {
+			*doneWriter
+			pusher
+			readerFrom
+		}{w, p, rf}
+	case isFlusher:
+		return struct {
+			*doneWriter
+			flusher
+		}{w, f}
+	case isHijacker:
+		return struct {
+			*doneWriter
+			hijacker
+		}{w, h}
+	case isPusher:
+		return struct {
+			*doneWriter
+			pusher
+		}{w, p}
+	case isReaderFrom:
+		return struct {
+			*doneWriter
+			readerFrom
+		}{w, rf}
+	}
+	return w
 }
 
 func (w *doneWriter) Done() bool {
@@ -59,6 +165,55 @@ func (w *doneWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// Unwrap returns the underlying http.ResponseWriter, for use by
+// http.ResponseController.
+func (w *doneWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type flusher struct {
+	w *doneWriter
+}
+
+// Flush sends any buffered data to the client, and marks the writer as done.
+func (f flusher) Flush() {
+	f.w.done = true
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct {
+	w *doneWriter
+}
+
+// Hijack takes over the connection, and marks the writer as done.
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.w.done = true
+	}
+	return conn, rw, err
+}
+
+type pusher struct {
+	w *doneWriter
+}
+
+// Push initiates an HTTP/2 server push.
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+type readerFrom struct {
+	w *doneWriter
+}
+
+// ReadFrom writes the contents of r to the underlying writer, and marks the
+// writer as done.
+func (rf readerFrom) ReadFrom(r io.Reader) (int64, error) {
+	rf.w.done = true
+	return rf.w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+}
+
 // WriterIsDone returns true if a response has been written. An error is
 // returned if the underlying writer is not a DoneWriter.
 func WriterIsDone(w http.ResponseWriter) (bool, error) {
@@ -73,6 +228,6 @@ func WriterIsDone(w http.ResponseWriter) (bool, error) {
 // WriterIsDone method to check the status.
 func WrapWriter(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(&doneWriter{ResponseWriter: w}, r)
+		next.ServeHTTP(New(w), r)
 	})
 }
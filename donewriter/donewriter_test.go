@@ -0,0 +1,178 @@
+package donewriter
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// plainWriter hides the optional interfaces of the recorder.
+type plainWriter struct{ http.ResponseWriter }
+
+// http1Writer has the optional interfaces of the HTTP/1.x server's writer.
+type http1Writer struct{ *httptest.ResponseRecorder }
+
+func (http1Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func (w http1Writer) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+// http2Writer has the optional interfaces of the HTTP/2 server's writer.
+type http2Writer struct{ *httptest.ResponseRecorder }
+
+func (http2Writer) Push(string, *http.PushOptions) error {
+	return nil
+}
+
+// fullWriter has all of the optional interfaces.
+type fullWriter struct{ http1Writer }
+
+func (fullWriter) Push(string, *http.PushOptions) error {
+	return nil
+}
+
+// plainReaderFrom implements only io.ReaderFrom.
+type plainReaderFrom struct{ http.ResponseWriter }
+
+func (w plainReaderFrom) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(w.ResponseWriter, r)
+}
+
+func interfaces(w http.ResponseWriter) string {
+	var names []string
+	if _, ok := w.(http.Flusher); ok {
+		names = append(names, "Flusher")
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		names = append(names, "Hijacker")
+	}
+	if _, ok := w.(http.Pusher); ok {
+		names = append(names, "Pusher")
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		names = append(names, "ReaderFrom")
+	}
+	return strings.Join(names, ",")
+}
+
+func TestNewInterfaces(t *testing.T) {
+	tests := []struct {
+		name     string
+		w        http.ResponseWriter
+		expected string
+	}{
+		{"plain", plainWriter{httptest.NewRecorder()}, ""},
+		{"reader from", plainReaderFrom{httptest.NewRecorder()}, "ReaderFrom"},
+		{"recorder", httptest.NewRecorder(), "Flusher"},
+		{"http/1", http1Writer{httptest.NewRecorder()}, "Flusher,Hijacker,ReaderFrom"},
+		{"http/2", http2Writer{httptest.NewRecorder()}, "Flusher,Pusher"},
+		{"all", fullWriter{http1Writer{httptest.NewRecorder()}}, "Flusher,Hijacker,Pusher,ReaderFrom"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := interfaces(test.w); result != test.expected {
+				t.Fatalf("Test writer has interfaces %q", result)
+			}
+			w := New(test.w)
+			if result := interfaces(w); result != test.expected {
+				t.Errorf("Expected interfaces %q, got %q", test.expected, result)
+			}
+			if w.Done() {
+				t.Error("New writer should not be done")
+			}
+		})
+	}
+}
+
+func TestDone(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   func(w DoneWriter)
+		expected bool
+	}{
+		{
+			name:   "header only",
+			action: func(w DoneWriter) { w.Header().Set("X-Test", "foo") },
+		},
+		{
+			name:     "write header",
+			action:   func(w DoneWriter) { w.WriteHeader(http.StatusNoContent) },
+			expected: true,
+		},
+		{
+			name:     "write",
+			action:   func(w DoneWriter) { _, _ = w.Write([]byte("foo")) },
+			expected: true,
+		},
+		{
+			name:     "flush",
+			action:   func(w DoneWriter) { w.(http.Flusher).Flush() },
+			expected: true,
+		},
+		{
+			name:     "hijack",
+			action:   func(w DoneWriter) { _, _, _ = w.(http.Hijacker).Hijack() },
+			expected: true,
+		},
+		{
+			name:   "push",
+			action: func(w DoneWriter) { _ = w.(http.Pusher).Push("/style.css", nil) },
+		},
+		{
+			name:     "read from",
+			action:   func(w DoneWriter) { _, _ = w.(io.ReaderFrom).ReadFrom(strings.NewReader("foo")) },
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := New(fullWriter{http1Writer{httptest.NewRecorder()}})
+			test.action(w)
+			if done := w.Done(); done != test.expected {
+				t.Errorf("Unexpected done state: %t", done)
+			}
+		})
+	}
+}
+
+func TestResponseController(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := New(plainWriter{rec})
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now()); err == nil {
+		t.Error("Expected an error from the recorder")
+	}
+	if err := rc.Flush(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Unexpected flush error: %v", err)
+	}
+	w = New(rec)
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Done() || !rec.Flushed {
+		t.Error("Expected writer to be flushed and done")
+	}
+}
+
+func TestWrapWriter(t *testing.T) {
+	var flushable bool
+	handler := WrapWriter(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, flushable = w.(http.Flusher)
+		if done, err := WriterIsDone(w); err != nil || done {
+			t.Errorf("Unexpected state: %t, %v", done, err)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !flushable {
+		t.Error("Expected wrapped writer to be a Flusher")
+	}
+}
@@ -0,0 +1,81 @@
+package httperr
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// StatusClientClosedRequest is the non-standard status code used when the
+// client cancelled the request before a response was sent.
+const StatusClientClosedRequest = 499
+
+// Classifier determines the status code for errors which do not embed one.
+// It returns the status code and true if it recognizes err, or false
+// otherwise. err's chain is not unwrapped before calling the classifier, so
+// classifiers should use errors.Is or errors.As.
+type Classifier func(err error) (status int, ok bool)
+
+// ClassifyIs returns a Classifier which returns status for errors matching
+// target, according to errors.Is.
+func ClassifyIs(target error, status int) Classifier {
+	return func(err error) (int, bool) {
+		return status, errors.Is(err, target)
+	}
+}
+
+var (
+	classifiersMu sync.RWMutex
+	classifiers   []Classifier
+)
+
+// defaultClassifiers are consulted after any registered classifiers.
+var defaultClassifiers = []Classifier{
+	ClassifyIs(context.DeadlineExceeded, http.StatusGatewayTimeout),
+	ClassifyIs(context.Canceled, StatusClientClosedRequest),
+	ClassifyIs(os.ErrNotExist, http.StatusNotFound),
+	ClassifyIs(os.ErrPermission, http.StatusForbidden),
+	ClassifyIs(sql.ErrNoRows, http.StatusNotFound),
+	func(err error) (int, bool) {
+		var mbe *http.MaxBytesError
+		return http.StatusRequestEntityTooLarge, errors.As(err, &mbe)
+	},
+}
+
+// RegisterClassifier registers c to determine the status code of errors which
+// do not embed one. Registered classifiers are consulted in the order they
+// were registered, and before the default classifiers, which recognize:
+//
+//  context.DeadlineExceeded  504 (gateway timeout)
+//  context.Canceled          499 (client closed request)
+//  fs.ErrNotExist            404 (not found)
+//  fs.ErrPermission          403 (forbidden)
+//  sql.ErrNoRows             404 (not found)
+//  *http.MaxBytesError       413 (request entity too large)
+//
+// Errors recognized by no classifier have status 500.
+func RegisterClassifier(c Classifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	classifiers = append(classifiers, c)
+}
+
+// classify returns the status code for err according to the registered and
+// default classifiers, or 500 if none recognizes err.
+func classify(err error) int {
+	classifiersMu.RLock()
+	registered := classifiers
+	classifiersMu.RUnlock()
+	for _, list := range [][]Classifier{registered, defaultClassifiers} {
+		for _, c := range list {
+			if status, ok := c(err); ok {
+				return status
+			}
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,90 @@
+package httperr
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	errCustom := errors.New("custom")
+	defer func(c []Classifier) {
+		classifiers = c
+	}(classifiers)
+	RegisterClassifier(ClassifyIs(errCustom, http.StatusTeapot))
+	RegisterClassifier(func(err error) (int, bool) {
+		return http.StatusBadGateway, strings.HasPrefix(err.Error(), "upstream:")
+	})
+	// Takes priority over the default classifier for sql.ErrNoRows
+	RegisterClassifier(ClassifyIs(sql.ErrNoRows, http.StatusGone))
+
+	_, statErr := os.Stat("testdata/does-not-exist")
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "unrecognized",
+			err:      errors.New("foo"),
+			expected: http.StatusInternalServerError,
+		},
+		{
+			name:     "deadline exceeded",
+			err:      fmt.Errorf("querying: %w", context.DeadlineExceeded),
+			expected: http.StatusGatewayTimeout,
+		},
+		{
+			name:     "canceled",
+			err:      context.Canceled,
+			expected: StatusClientClosedRequest,
+		},
+		{
+			name:     "not exist",
+			err:      statErr,
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "permission",
+			err:      &os.PathError{Op: "open", Path: "/etc/shadow", Err: os.ErrPermission},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "max bytes",
+			err:      fmt.Errorf("reading body: %w", &http.MaxBytesError{Limit: 10}),
+			expected: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "registered",
+			err:      fmt.Errorf("wrapped: %w", errCustom),
+			expected: http.StatusTeapot,
+		},
+		{
+			name:     "registered func",
+			err:      errors.New("upstream: connection refused"),
+			expected: http.StatusBadGateway,
+		},
+		{
+			name:     "registered before default",
+			err:      sql.ErrNoRows,
+			expected: http.StatusGone,
+		},
+		{
+			name:     "status code takes precedence",
+			err:      Wrap(http.StatusServiceUnavailable, context.DeadlineExceeded),
+			expected: http.StatusServiceUnavailable,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := StatusCode(test.err); status != test.expected {
+				t.Errorf("Unexpected status: %d", status)
+			}
+		})
+	}
+}
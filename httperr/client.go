@@ -0,0 +1,115 @@
+package httperr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxResponseBody is the maximum number of bytes of an error response body
+// read by FromResponse.
+const maxResponseBody = 64 << 10
+
+// responseHeaders are the response headers preserved by FromResponse, so that
+// they are sent again if the error is served.
+var responseHeaders = []string{"Allow", "Location", "Retry-After", "WWW-Authenticate"}
+
+// textPattern matches the plain text responses served by HandleError. The
+// message may span several lines, as it does for aggregate errors.
+var textPattern = regexp.MustCompile(`(?s)^Error (\d{3})(?: \[([^\]]+)\])?: (.*?)(?: \(correlation ID: ([^)]+)\))?$`)
+
+// FromResponse returns an error describing resp, if its status is not 2xx.
+// The error's status code is that of the response, and its message, code and
+// correlation ID are taken from the body, if it is a plain text response as
+// served by HandleError, or an application/problem+json document. In the
+// latter case, the error's chain contains the decoded *Problem. The Allow,
+// Location, Retry-After and WWW-Authenticate headers of the response are
+// attached to the error.
+//
+// Up to 64 KiB of the body are read, after which resp.Body is replaced so that
+// it may be read again by the caller. If resp is nil, or its status is 2xx,
+// nil is returned and the body is not read.
+func FromResponse(resp *http.Response) error {
+	if resp == nil || resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	var body []byte
+	if resp.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		if err != nil {
+			return Wrapf(resp.StatusCode, err, "failed to read response body")
+		}
+	}
+	var opts []Option
+	for _, key := range responseHeaders {
+		for _, value := range resp.Header[key] {
+			opts = append(opts, WithHeader(key, value))
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case ContentTypeProblemJSON:
+		p := &Problem{}
+		if err := json.Unmarshal(body, p); err == nil {
+			if p.Status == 0 {
+				p.Status = resp.StatusCode
+			}
+			return Wrap(resp.StatusCode, p, opts...)
+		}
+	case "text/plain", "":
+		m := textPattern.FindStringSubmatch(strings.TrimSpace(string(body)))
+		if m != nil && m[1] == strconv.Itoa(resp.StatusCode) {
+			if m[2] != "" {
+				opts = append(opts, WithCode(m[2]))
+			}
+			if m[4] != "" {
+				opts = append(opts, WithExtension("correlation_id", m[4]))
+			}
+			return New(resp.StatusCode, m[3], opts...)
+		}
+	}
+	msg := http.StatusText(resp.StatusCode)
+	if msg == "" {
+		msg = "unexpected response status " + strconv.Itoa(resp.StatusCode)
+	}
+	return New(resp.StatusCode, msg, opts...)
+}
+
+// Transport is an http.RoundTripper which converts 4xx and 5xx responses to
+// errors with FromResponse, so that clients using it receive errors which
+// embed the status code of the response. Other responses, including
+// redirects, are returned unchanged.
+type Transport struct {
+	// Base is the underlying RoundTripper. If nil, http.DefaultTransport is
+	// used.
+	Base http.RoundTripper
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// RoundTrip executes a single HTTP transaction. If the response status is 4xx
+// or 5xx, the response body is closed, and the error returned by
+// FromResponse is returned in place of the response.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	return nil, FromResponse(resp)
+}
@@ -0,0 +1,225 @@
+package httperr
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestFromResponse(t *testing.T) {
+	type expected struct {
+		Status  int
+		Message string
+		Code    string
+		Header  http.Header
+		Problem *Problem
+	}
+	tests := []struct {
+		name     string
+		resp     *http.Response
+		expected *expected
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "success",
+			resp: &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("ok"))},
+		},
+		{
+			name: "plain text",
+			resp: &http.Response{
+				StatusCode: http.StatusNotFound,
+				Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				Body:       ioutil.NopCloser(strings.NewReader("Error 404: user not found")),
+			},
+			expected: &expected{Status: http.StatusNotFound, Message: "user not found"},
+		},
+		{
+			name: "plain text with code and correlation ID",
+			resp: &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       ioutil.NopCloser(strings.NewReader("Error 500 [db.down]: Internal Server Error (correlation ID: abc123)")),
+			},
+			expected: &expected{Status: http.StatusInternalServerError, Message: "Internal Server Error", Code: "db.down"},
+		},
+		{
+			name: "plain text, status mismatch",
+			resp: &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Body:       ioutil.NopCloser(strings.NewReader("Error 404: user not found")),
+			},
+			expected: &expected{Status: http.StatusBadGateway, Message: "Bad Gateway"},
+		},
+		{
+			name: "problem json",
+			resp: &http.Response{
+				StatusCode: http.StatusConflict,
+				Header:     http.Header{"Content-Type": {ContentTypeProblemJSON}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"title":"Conflict","status":409,"detail":"email taken","code":"user.email_taken","field":"email"}`)),
+			},
+			expected: &expected{
+				Status:  http.StatusConflict,
+				Message: "email taken",
+				Code:    "user.email_taken",
+				Problem: &Problem{
+					Title:      "Conflict",
+					Status:     http.StatusConflict,
+					Detail:     "email taken",
+					Extensions: map[string]interface{}{"code": "user.email_taken", "field": "email"},
+				},
+			},
+		},
+		{
+			name: "invalid problem json",
+			resp: &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Header:     http.Header{"Content-Type": {ContentTypeProblemJSON}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"status":"bad"}`)),
+			},
+			expected: &expected{Status: http.StatusBadRequest, Message: "Bad Request"},
+		},
+		{
+			name: "html",
+			resp: &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header: http.Header{
+					"Content-Type": {"text/html"},
+					"Retry-After":  {"30"},
+					"Server":       {"nginx"},
+				},
+				Body: ioutil.NopCloser(strings.NewReader("<h1>Error 503: down</h1>")),
+			},
+			expected: &expected{
+				Status:  http.StatusServiceUnavailable,
+				Message: "Service Unavailable",
+				Header:  http.Header{"Retry-After": {"30"}},
+			},
+		},
+		{
+			name:     "no body",
+			resp:     &http.Response{StatusCode: 599},
+			expected: &expected{Status: 599, Message: "unexpected response status 599"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := FromResponse(test.resp)
+			if test.expected == nil {
+				if err != nil {
+					t.Errorf("Unexpected error: %s", err)
+				}
+				return
+			}
+			var p *Problem
+			_ = errors.As(err, &p)
+			result := &expected{
+				Status:  StatusCode(err),
+				Message: err.Error(),
+				Code:    Code(err),
+				Header:  Headers(err),
+				Problem: p,
+			}
+			if d := diff.Interface(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestFromResponseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{
+			name: "status error",
+			err:  New(http.StatusNotFound, "no such user", WithCode("user.missing")),
+		},
+		{
+			name: "aggregate",
+			err:  Wrap(http.StatusNotFound, Join(New(http.StatusNotFound, "a"), New(http.StatusNotFound, "b")), WithCode("batch.failed")),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := HandleError(w, test.err); err != nil {
+				t.Fatal(err)
+			}
+			err := FromResponse(w.Result())
+			if status := StatusCode(err); status != StatusCode(test.err) {
+				t.Errorf("Unexpected status: %d", status)
+			}
+			if d := diff.Text(PublicMessage(test.err), err.Error()); d != nil {
+				t.Error(d)
+			}
+			if code := Code(err); code != Code(test.err) {
+				t.Errorf("Unexpected code: %s", code)
+			}
+		})
+	}
+}
+
+func TestFromResponseBody(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       ioutil.NopCloser(strings.NewReader("Error 404: not found")),
+	}
+	_ = FromResponse(resp)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.Text("Error 404: not found", string(body)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte("ok"))
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			_ = HandleRequestError(w, r, NewCode(testCodeEmailTaken))
+		}
+	}))
+	defer s.Close()
+	client := &http.Client{Transport: &Transport{}}
+
+	t.Run("success", func(t *testing.T) {
+		resp, err := client.Get(s.URL + "/moved")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		if d := diff.Text("ok", string(body)); d != nil {
+			t.Error(d)
+		}
+	})
+	for _, accept := range []string{"text/plain", ContentTypeProblemJSON} {
+		t.Run(accept, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, s.URL+"/fail", nil)
+			req.Header.Set("Accept", accept)
+			_, err := client.Do(req)
+			if status := StatusCode(err); status != http.StatusConflict {
+				t.Errorf("Unexpected status: %d", status)
+			}
+			if code := Code(err); code != testCodeEmailTaken {
+				t.Errorf("Unexpected code: %s", code)
+			}
+			if msg := PublicMessage(errors.Unwrap(err)); msg != "Email address already in use" {
+				t.Errorf("Unexpected message: %s", msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package httperr
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// errorCoder is implemented by errors which carry a machine-readable
+// application error code. Like statusCoder, it is not exported, but is
+// considered part of the stable public API.
+//
+//  type errorCoder interface {
+//      ErrorCode() string
+//  }
+type errorCoder interface {
+	ErrorCode() string
+}
+
+// CodeInfo describes a registered application error code.
+type CodeInfo struct {
+	// Code is the stable, machine-readable code, such as "user.email_taken".
+	Code string
+	// Status is the default HTTP status code for errors with this code.
+	Status int
+	// Message is the default message for errors with this code.
+	Message string
+}
+
+var (
+	codesMu sync.RWMutex
+	codes   = make(map[string]CodeInfo)
+)
+
+// RegisterCode declares an application error code, with its default status
+// and message. It is intended to be called during initialization:
+//
+//  var _ = httperr.RegisterCode("user.email_taken", http.StatusConflict, "Email address already in use")
+//
+// RegisterCode panics if the code is empty or already registered. The code is
+// returned for convenience.
+func RegisterCode(code string, status int, message string) string {
+	if code == "" {
+		panic("httperr: empty error code")
+	}
+	codesMu.Lock()
+	defer codesMu.Unlock()
+	if _, ok := codes[code]; ok {
+		panic("httperr: error code registered twice: " + code)
+	}
+	codes[code] = CodeInfo{Code: code, Status: status, Message: message}
+	return code
+}
+
+// LookupCode returns the registration of code, and true, or false if code is
+// not registered.
+func LookupCode(code string) (CodeInfo, bool) {
+	codesMu.RLock()
+	defer codesMu.RUnlock()
+	info, ok := codes[code]
+	return info, ok
+}
+
+// WithCode attaches a machine-readable application error code to the error.
+func WithCode(code string) Option {
+	return func(e *statusError) {
+		e.code = code
+	}
+}
+
+// NewCode returns a new error with a registered application error code, and
+// the code's default status and message. If code is not registered, the
+// error has status 500, and the code as its message.
+func NewCode(code string, opts ...Option) error {
+	info, ok := LookupCode(code)
+	if !ok {
+		info = CodeInfo{Status: http.StatusInternalServerError, Message: code}
+	}
+	return New(info.Status, info.Message, append([]Option{WithCode(code)}, opts...)...)
+}
+
+// Code returns the application error code of the outermost error in err's
+// chain which has one, or an empty string.
+func Code(err error) string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if coder, ok := e.(errorCoder); ok {
+			if code := coder.ErrorCode(); code != "" {
+				return code
+			}
+		}
+	}
+	return ""
+}
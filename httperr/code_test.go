@@ -0,0 +1,176 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+var testCodeEmailTaken = RegisterCode("test.email_taken", http.StatusConflict, "Email address already in use")
+
+func TestRegisterCode(t *testing.T) {
+	t.Run("duplicate", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected a panic")
+			}
+		}()
+		RegisterCode(testCodeEmailTaken, http.StatusConflict, "again")
+	})
+	t.Run("empty", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected a panic")
+			}
+		}()
+		RegisterCode("", http.StatusConflict, "empty")
+	})
+	t.Run("lookup", func(t *testing.T) {
+		expected := CodeInfo{Code: "test.email_taken", Status: http.StatusConflict, Message: "Email address already in use"}
+		info, ok := LookupCode(testCodeEmailTaken)
+		if !ok {
+			t.Fatal("Code not found")
+		}
+		if d := diff.Interface(expected, info); d != nil {
+			t.Error(d)
+		}
+		if _, ok := LookupCode("test.unknown"); ok {
+			t.Error("Unexpected registration")
+		}
+	})
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+		status   int
+		msg      string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:   "no code",
+			err:    errors.New("foo"),
+			status: http.StatusInternalServerError,
+			msg:    "foo",
+		},
+		{
+			name:     "registered",
+			err:      NewCode(testCodeEmailTaken),
+			expected: "test.email_taken",
+			status:   http.StatusConflict,
+			msg:      "Email address already in use",
+		},
+		{
+			name:     "unregistered",
+			err:      NewCode("test.unknown"),
+			expected: "test.unknown",
+			status:   http.StatusInternalServerError,
+			msg:      "test.unknown",
+		},
+		{
+			name:     "explicit status",
+			err:      New(http.StatusConflict, "username taken", WithCode("test.username_taken")),
+			expected: "test.username_taken",
+			status:   http.StatusConflict,
+			msg:      "username taken",
+		},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("signing up: %w", NewCode(testCodeEmailTaken)),
+			expected: "test.email_taken",
+			status:   http.StatusConflict,
+			msg:      "signing up: Email address already in use",
+		},
+		{
+			name:     "outermost code",
+			err:      Wrap(http.StatusBadRequest, NewCode(testCodeEmailTaken), WithCode("test.outer")),
+			expected: "test.outer",
+			status:   http.StatusBadRequest,
+			msg:      "Email address already in use",
+		},
+		{
+			name:     "problem",
+			err:      &Problem{Status: http.StatusConflict, Extensions: map[string]interface{}{"code": "test.problem"}},
+			expected: "test.problem",
+			status:   http.StatusConflict,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := Code(test.err); code != test.expected {
+				t.Errorf("Unexpected code: %s", code)
+			}
+			if test.err == nil {
+				return
+			}
+			if status := StatusCode(test.err); status != test.status {
+				t.Errorf("Unexpected status: %d", status)
+			}
+			if msg := test.err.Error(); msg != test.msg {
+				t.Errorf("Unexpected message: %s", msg)
+			}
+		})
+	}
+}
+
+func TestHandleErrorCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		err    error
+		body   string
+	}{
+		{
+			name: "plain text",
+			err:  NewCode(testCodeEmailTaken),
+			body: "Error 409 [test.email_taken]: Email address already in use",
+		},
+		{
+			name:   "problem json",
+			accept: ContentTypeProblemJSON,
+			err:    NewCode(testCodeEmailTaken),
+			body:   `{"code":"test.email_taken","detail":"Email address already in use","status":409,"title":"Conflict"}`,
+		},
+		{
+			name:   "problem xml",
+			accept: ContentTypeProblemXML,
+			err:    NewCode(testCodeEmailTaken),
+			body:   `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<problem xmlns="urn:ietf:rfc:7807"><code>test.email_taken</code><detail>Email address already in use</detail><status>409</status><title>Conflict</title></problem>`,
+		},
+		{
+			name: "validation",
+			err: Wrap(http.StatusUnprocessableEntity,
+				(&ValidationError{}).Add("email", "required", "Email is required"),
+				WithCode("test.invalid_signup")),
+			body: `{"code":"test.invalid_signup","detail":"validation failed: email: Email is required","errors":[{"field":"email","code":"required","message":"Email is required"}],"status":422,"title":"Unprocessable Entity"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", test.accept)
+			if err := HandleRequestError(w, r, test.err); err != nil {
+				t.Fatal(err)
+			}
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package httperr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+// Config holds the settings which control how errors are served, logged and
+// reported. A Config may be used directly, through its methods, or installed
+// in a request context with WithConfig or Config.Middleware, where it is found
+// by the functions of this package which take a request, such as
+// HandleRequestError, Respond, WantsDebugPage and ReportError.
+//
+// The package-level variables Production, Development, AbortStartedResponse,
+// ErrorLog, NewCorrelationID, DefaultPrecedence, DefaultStatusPolicy and
+// DefaultReporter are only defaults, used by DefaultConfig when no Config is
+// given. They are not synchronized, so they should be set, if at all, before
+// any requests are served.
+//
+// The zero value is ready to use, with every mode disabled. Nil fields fall
+// back to built-in defaults, not to the package-level variables. A Config must
+// not be modified once it is in use.
+type Config struct {
+	// Production hides the internal messages of 5xx errors from clients, as
+	// described for the package-level Production.
+	Production bool
+	// Development serves the HTML debug page for 5xx errors, unless
+	// Production is also set. See WantsDebugPage.
+	Development bool
+	// AbortStartedResponse aborts the handler with http.ErrAbortHandler when
+	// an error cannot be served, because a response has already been written.
+	AbortStartedResponse bool
+	// ErrorLog is the logger to which internal errors are logged. If nil,
+	// errors are logged via the log package's standard logger.
+	ErrorLog *log.Logger
+	// NewCorrelationID returns a new correlation ID for a hidden error. If
+	// nil, a random ID is generated.
+	NewCorrelationID func() string
+	// Precedence determines which status code is used when an error chain
+	// contains more than one.
+	Precedence Precedence
+	// StatusPolicy computes the status code of aggregate errors with no policy
+	// of their own. If nil, MostSevere is used.
+	StatusPolicy StatusPolicy
+	// Reporter, if set, receives a report of each error served.
+	Reporter Reporter
+}
+
+// DefaultConfig returns a new Config populated from the package-level
+// variables.
+func DefaultConfig() *Config {
+	return &Config{
+		Production:           Production,
+		Development:          Development,
+		AbortStartedResponse: AbortStartedResponse,
+		ErrorLog:             ErrorLog,
+		NewCorrelationID:     NewCorrelationID,
+		Precedence:           DefaultPrecedence,
+		StatusPolicy:         DefaultStatusPolicy,
+		Reporter:             DefaultReporter,
+	}
+}
+
+// configContextKey is a context key used to fetch the Config from a context.
+// The returned value is of type *Config.
+var configContextKey = &contextKey{"config"}
+
+// WithConfig returns a copy of r whose context carries c, which is then used
+// to serve, log and report errors in response to the request.
+func WithConfig(r *http.Request, c *Config) *http.Request {
+	ctx := context.WithValue(r.Context(), configContextKey, c)
+	return r.WithContext(ctx)
+}
+
+// GetConfig returns the Config carried by r's context, or DefaultConfig() if
+// there is none. r may be nil.
+func GetConfig(r *http.Request) *Config {
+	if r != nil {
+		if c, ok := r.Context().Value(configContextKey).(*Config); ok {
+			return c
+		}
+	}
+	return DefaultConfig()
+}
+
+// Middleware installs c in the context of each request, with WithConfig.
+func (c *Config) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, WithConfig(r, c))
+	})
+}
+
+// Responder returns an ErrorResponder which serves errors with
+// c.HandleRequestError.
+func (c *Config) Responder() ErrorResponder {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		_ = c.HandleRequestError(w, r, err)
+	}
+}
+
+func (c *Config) newCorrelationID() string {
+	if c.NewCorrelationID != nil {
+		return c.NewCorrelationID()
+	}
+	return randomCorrelationID()
+}
+
+func (c *Config) statusPolicy() StatusPolicy {
+	if c.StatusPolicy != nil {
+		return c.StatusPolicy
+	}
+	return MostSevere
+}
+
+// logf logs to c.ErrorLog, or to the standard logger if it is nil.
+func (c *Config) logf(format string, args ...interface{}) {
+	if c.ErrorLog != nil {
+		c.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func randomCorrelationID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,137 @@
+package httperr
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestConfigHandleRequestError(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		accept string
+		e      error
+		status int
+		body   string
+	}{
+		{
+			name:   "zero value",
+			config: &Config{},
+			e:      errors.New("database is down"),
+			status: http.StatusInternalServerError,
+			body:   "Error 500: database is down",
+		},
+		{
+			name:   "production",
+			config: &Config{Production: true, NewCorrelationID: func() string { return "abc123" }},
+			e:      errors.New("database is down"),
+			status: http.StatusInternalServerError,
+			body:   "Error 500: Internal Server Error (correlation ID: abc123)",
+		},
+		{
+			name:   "innermost precedence",
+			config: &Config{Precedence: Innermost},
+			e:      Wrap(http.StatusBadRequest, New(http.StatusNotFound, "no such user")),
+			status: http.StatusNotFound,
+			body:   "Error 404: no such user",
+		},
+		{
+			name:   "status policy",
+			config: &Config{StatusPolicy: First},
+			e:      errors.Join(New(http.StatusNotFound, "a"), New(http.StatusBadGateway, "b")),
+			status: http.StatusNotFound,
+			body:   "Error 404: a\nb",
+		},
+		{
+			name:   "production problem members",
+			config: &Config{Production: true},
+			accept: "application/json",
+			e:      Join(New(http.StatusNotFound, "a"), errors.New("secret")),
+			status: http.StatusInternalServerError,
+			body:   `{"detail":"a\nInternal Server Error","errors":[{"status":404,"message":"a"},{"status":500,"message":"Internal Server Error"}],"status":500,"title":"Internal Server Error"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(p bool) { Production = p }(Production)
+			Production = false
+			test.config.ErrorLog = log.New(ioutil.Discard, "", 0)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", test.accept)
+			if err := test.config.HandleRequestError(w, r, test.e); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != test.status {
+				t.Errorf("Unexpected status code: %d", w.Code)
+			}
+			if d := diff.Text(test.body, w.Body.String()); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestConfigMiddleware(t *testing.T) {
+	defer func(p bool) { Production = p }(Production)
+	Production = false
+	buf := &bytes.Buffer{}
+	reporter := &MemoryReporter{}
+	c := &Config{
+		Production:       true,
+		ErrorLog:         log.New(buf, "", 0),
+		NewCorrelationID: func() string { return "abc123" },
+		Reporter:         reporter,
+	}
+	h := c.Middleware(HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+		return errors.New("database is down")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if d := diff.Text("Error 500: Internal Server Error (correlation ID: abc123)", w.Body.String()); d != nil {
+		t.Error(d)
+	}
+	if d := diff.Text("[abc123] Error 500: database is down\n", buf.String()); d != nil {
+		t.Error(d)
+	}
+	if reports := reporter.Reports(); len(reports) != 1 || reports[0].CorrelationID != "abc123" {
+		t.Errorf("Unexpected reports: %v", reports)
+	}
+}
+
+func TestConfigResponder(t *testing.T) {
+	c := &Config{Precedence: Innermost}
+	r := WithResponder(httptest.NewRequest(http.MethodGet, "/", nil), c.Responder())
+	w := httptest.NewRecorder()
+	Respond(w, r, Wrap(http.StatusBadRequest, New(http.StatusNotFound, "no such user")))
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("Unexpected status code: %d", res.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if !strings.HasPrefix(string(body), "Error 404:") {
+		t.Errorf("Unexpected body: %s", body)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	defer func(p bool) { Production = p }(Production)
+	Production = true
+	if c := GetConfig(nil); !c.Production {
+		t.Error("Expected the default config to follow Production")
+	}
+	c := &Config{}
+	r := WithConfig(httptest.NewRequest(http.MethodGet, "/", nil), c)
+	if got := GetConfig(r); got != c {
+		t.Errorf("Unexpected config: %v", got)
+	}
+}
@@ -0,0 +1,268 @@
+package httperr
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/flimzy/juniper/donewriter"
+)
+
+// Development, when true and Production is false, serves an HTML debug page in
+// place of the usual response for 5xx errors, to requests which prefer
+// text/html, such as those made by browsers. The page shows the error chain,
+// the stack trace with source excerpts, and the request headers. It is the
+// default for Config.Development.
+var Development = false
+
+// sourceContext is the number of source lines shown before and after each
+// stack frame's line on the debug page.
+const sourceContext = 3
+
+// DebugSection is an additional section of the debug page, such as the view
+// stash.
+type DebugSection struct {
+	Title  string
+	Values map[string]interface{}
+}
+
+// WantsDebugPage returns true if the debug page should be served for err in
+// response to r. This is the case in Development mode, when not in Production
+// mode, for 5xx errors, if r prefers text/html to the other supported formats.
+// The modes are those of the request's Config.
+func WantsDebugPage(r *http.Request, err error) bool {
+	return GetConfig(r).wantsDebugPage(r, err)
+}
+
+func (c *Config) wantsDebugPage(r *http.Request, err error) bool {
+	if !c.Development || c.Production || r == nil || err == nil || c.StatusCode(err) < http.StatusInternalServerError {
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return false
+	}
+	q := acceptQuality(accept, "text/html")
+	for _, f := range formats {
+		if acceptQuality(accept, f.mediaType) >= q {
+			return false
+		}
+	}
+	return true
+}
+
+type debugFrame struct {
+	Function string
+	File     string
+	Line     int
+	Source   []debugLine
+}
+
+type debugLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+type debugError struct {
+	Type    string
+	Message string
+}
+
+type debugValue struct {
+	Key   string
+	Value string
+}
+
+type debugSection struct {
+	Title  string
+	Values []debugValue
+}
+
+type debugPage struct {
+	Status   int
+	Title    string
+	Message  string
+	Chain    []debugError
+	Stack    []debugFrame
+	Request  string
+	Sections []debugSection
+}
+
+var debugTemplate = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Error {{ .Status }}: {{ .Title }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+.current { background: #fdd; font-weight: bold; }
+td { vertical-align: top; padding-right: 1em; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>Error {{ .Status }}: {{ .Title }}</h1>
+<p>{{ .Message }}</p>
+<h2>Error chain</h2>
+<ol>
+{{- range .Chain }}
+<li><code>{{ .Type }}</code>: {{ .Message }}</li>
+{{- end }}
+</ol>
+{{- if .Stack }}
+<h2>Stack trace</h2>
+{{- range .Stack }}
+<h3><code>{{ .Function }}</code></h3>
+<p><code>{{ .File }}:{{ .Line }}</code></p>
+{{- if .Source }}
+<pre>
+{{- range .Source }}
+<span{{ if .Current }} class="current"{{ end }}>{{ printf "%4d" .Number }}  {{ .Text }}</span>
+{{- end }}
+</pre>
+{{- end }}
+{{- end }}
+{{- end }}
+<h2>Request</h2>
+<pre>{{ .Request }}</pre>
+{{- range .Sections }}
+<h2>{{ .Title }}</h2>
+<table>
+{{- range .Values }}
+<tr><td>{{ .Key }}</td><td>{{ .Value }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
+
+// WriteDebugPage serves the HTML debug page for err, regardless of
+// Development mode, along with any additional sections. It should only be
+// used when WantsDebugPage returns true, as the page exposes internal
+// details. The error is reported to the Reporter of the request's Config, if
+// set.
+//
+// As with HandleError, if a response has already been written to w, the
+// error is only logged and reported, and ErrResponseStarted is returned.
+func WriteDebugPage(w http.ResponseWriter, r *http.Request, err error, sections ...DebugSection) error {
+	return GetConfig(r).writeDebugPage(w, r, err, sections...)
+}
+
+func (c *Config) writeDebugPage(w http.ResponseWriter, r *http.Request, err error, sections ...DebugSection) error {
+	if done, _ := donewriter.WriterIsDone(w); done {
+		return c.responseStarted(r, err)
+	}
+	status := c.StatusCode(err)
+	page := &debugPage{
+		Status:  status,
+		Title:   http.StatusText(status),
+		Message: err.Error(),
+		Stack:   debugStack(err),
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		page.Chain = append(page.Chain, debugError{Type: fmt.Sprintf("%T", e), Message: e.Error()})
+	}
+	if r != nil {
+		var req strings.Builder
+		fmt.Fprintf(&req, "%s %s %s\n", r.Method, r.URL, r.Proto)
+		fmt.Fprintf(&req, "Host: %s\n", r.Host)
+		_ = r.Header.WriteSubset(&req, nil)
+		page.Request = req.String()
+	}
+	for _, s := range sections {
+		section := debugSection{Title: s.Title}
+		for key, value := range s.Values {
+			section.Values = append(section.Values, debugValue{Key: key, Value: fmt.Sprintf("%#v", value)})
+		}
+		sort.Slice(section.Values, func(i, j int) bool {
+			return section.Values[i].Key < section.Values[j].Key
+		})
+		page.Sections = append(page.Sections, section)
+	}
+	setHeaders(w, err)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	e := debugTemplate.Execute(w, page)
+	c.report(r, err, status, "")
+	return e
+}
+
+// debugStack returns the innermost stack trace in err's chain, captured by
+// github.com/pkg/errors or by Recover.
+func debugStack(err error) []debugFrame {
+	var frames []debugFrame
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch t := e.(type) {
+		case *PanicError:
+			frames = parseStack(string(t.Stack))
+		case stackTracer:
+			frames = frames[:0]
+			for _, f := range t.StackTrace() {
+				pc := uintptr(f) - 1
+				fn := runtime.FuncForPC(pc)
+				if fn == nil {
+					continue
+				}
+				file, line := fn.FileLine(pc)
+				frames = append(frames, debugFrame{Function: fn.Name(), File: file, Line: line})
+			}
+		}
+	}
+	for i := range frames {
+		frames[i].Source = sourceExcerpt(frames[i].File, frames[i].Line)
+	}
+	return frames
+}
+
+// parseStack parses a goroutine stack trace, as returned by debug.Stack.
+func parseStack(stack string) []debugFrame {
+	var frames []debugFrame
+	lines := strings.Split(stack, "\n")
+	for i := 1; i+1 < len(lines); i += 2 {
+		fn, loc := lines[i], strings.TrimSpace(lines[i+1])
+		if offset := strings.LastIndex(loc, " +0x"); offset >= 0 {
+			loc = loc[:offset]
+		}
+		sep := strings.LastIndex(loc, ":")
+		if sep < 0 {
+			continue
+		}
+		line, err := strconv.Atoi(loc[sep+1:])
+		if err != nil {
+			continue
+		}
+		if args := strings.LastIndex(fn, "("); args > 0 {
+			fn = fn[:args]
+		}
+		frames = append(frames, debugFrame{Function: fn, File: loc[:sep], Line: line})
+	}
+	return frames
+}
+
+// sourceExcerpt returns the lines of file surrounding line, or nil if the file
+// cannot be read.
+func sourceExcerpt(file string, line int) []debugLine {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var excerpt []debugLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= line+sourceContext; n++ {
+		if n >= line-sourceContext {
+			excerpt = append(excerpt, debugLine{Number: n, Text: scanner.Text(), Current: n == line})
+		}
+	}
+	return excerpt
+}
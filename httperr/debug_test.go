@@ -0,0 +1,167 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestWantsDebugPage(t *testing.T) {
+	const browser = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	tests := []struct {
+		name        string
+		development bool
+		production  bool
+		accept      string
+		err         error
+		expected    bool
+	}{
+		{
+			name:   "development off",
+			accept: browser,
+			err:    errors.New("foo"),
+		},
+		{
+			name:        "browser",
+			development: true,
+			accept:      browser,
+			err:         errors.New("foo"),
+			expected:    true,
+		},
+		{
+			name:        "production",
+			development: true,
+			production:  true,
+			accept:      browser,
+			err:         errors.New("foo"),
+		},
+		{
+			name:        "client error",
+			development: true,
+			accept:      browser,
+			err:         New(http.StatusNotFound, "not found"),
+		},
+		{
+			name:        "no accept header",
+			development: true,
+			err:         errors.New("foo"),
+		},
+		{
+			name:        "any type",
+			development: true,
+			accept:      "*/*",
+			err:         errors.New("foo"),
+		},
+		{
+			name:        "json",
+			development: true,
+			accept:      "application/json, text/html;q=0.5",
+			err:         errors.New("foo"),
+		},
+		{
+			name:        "nil error",
+			development: true,
+			accept:      browser,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(d, p bool) {
+				Development, Production = d, p
+			}(Development, Production)
+			Development, Production = test.development, test.production
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			if result := WantsDebugPage(r, test.err); result != test.expected {
+				t.Errorf("Unexpected result: %t", result)
+			}
+		})
+	}
+}
+
+func TestWriteDebugPage(t *testing.T) {
+	defer func(d bool) {
+		Development = d
+	}(Development)
+	Development = true
+
+	tests := []struct {
+		name     string
+		err      error
+		contains []string
+	}{
+		{
+			name: "wrapped error",
+			err:  fmt.Errorf("loading user: %w", Wrap(http.StatusBadGateway, pkgerrors.New("connection <refused>"))),
+			contains: []string{
+				"<title>Error 502: Bad Gateway</title>",
+				"<code>*fmt.wrapError</code>: loading user: connection &lt;refused&gt;",
+				"<code>*httperr.statusError</code>",
+				"<code>*errors.fundamental</code>: connection &lt;refused&gt;",
+				"httperr.TestWriteDebugPage",
+				"debug_test.go:",
+				`<span class="current">`,
+				"pkgerrors.New(&#34;connection &lt;refused&gt;&#34;)",
+				"GET /users/1 HTTP/1.1",
+				"X-Test: foo",
+				"<h2>Extra</h2>",
+				"<tr><td>answer</td><td>42</td></tr>",
+			},
+		},
+		{
+			name: "panic",
+			err:  &PanicError{Value: "oops", Stack: debug.Stack()},
+			contains: []string{
+				"<title>Error 500: Internal Server Error</title>",
+				"<code>*httperr.PanicError</code>: panic: oops",
+				"runtime/debug.Stack",
+				"httperr.TestWriteDebugPage",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			r.Header.Set("Accept", "text/html")
+			r.Header.Set("X-Test", "foo")
+			if err := WriteDebugPage(w, r, test.err, DebugSection{Title: "Extra", Values: map[string]interface{}{"answer": 42}}); err != nil {
+				t.Fatal(err)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+				t.Errorf("Unexpected content type: %s", ct)
+			}
+			body := w.Body.String()
+			for _, s := range test.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("Expected body to contain %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRequestErrorDebugPage(t *testing.T) {
+	defer func(d bool) {
+		Development = d
+	}(Development)
+	Development = true
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html")
+	_ = HandleRequestError(w, r, errors.New("foo"))
+	if status := w.Code; status != http.StatusInternalServerError {
+		t.Errorf("Unexpected status: %d", status)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<h2>Error chain</h2>") {
+		t.Errorf("Expected debug page, got:\n%s", body)
+	}
+}
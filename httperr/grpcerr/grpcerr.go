@@ -0,0 +1,151 @@
+// Package grpcerr maps between httperr status codes and gRPC status codes, for
+// HTTP services which front gRPC backends. It is a separate package so that
+// users of httperr who do not need it do not depend on the gRPC module.
+//
+// The gRPC module is an optional dependency of juniper, which is not listed in
+// the root glide.yaml. This package has its own glide.yaml, from which gRPC is
+// installed into this package's vendor directory:
+//
+//  cd httperr/grpcerr && glide install
+//
+// Codes are mapped to HTTP status codes as by grpc-gateway, and HTTP status
+// codes to gRPC codes as described by google.rpc.Code.
+package grpcerr
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+
+	"github.com/flimzy/juniper/httperr"
+)
+
+// httpStatus maps gRPC codes to HTTP status codes, following grpc-gateway.
+var httpStatus = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           httperr.StatusClientClosedRequest,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+}
+
+// grpcCode maps HTTP status codes to gRPC codes, following google.rpc.Code.
+var grpcCode = map[int]codes.Code{
+	http.StatusBadRequest:             codes.InvalidArgument,
+	http.StatusUnauthorized:           codes.Unauthenticated,
+	http.StatusForbidden:              codes.PermissionDenied,
+	http.StatusNotFound:               codes.NotFound,
+	http.StatusConflict:               codes.Aborted,
+	http.StatusTooManyRequests:        codes.ResourceExhausted,
+	httperr.StatusClientClosedRequest: codes.Canceled,
+	http.StatusInternalServerError:    codes.Internal,
+	http.StatusNotImplemented:         codes.Unimplemented,
+	http.StatusServiceUnavailable:     codes.Unavailable,
+	http.StatusGatewayTimeout:         codes.DeadlineExceeded,
+}
+
+// HTTPStatus returns the HTTP status code corresponding to gRPC code c. Unknown
+// codes map to 500 (internal server error).
+func HTTPStatus(c codes.Code) int {
+	if status, ok := httpStatus[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Code returns the gRPC code corresponding to HTTP status code status. 2xx
+// status codes map to codes.OK, and other status codes with no corresponding
+// gRPC code to codes.Unknown.
+func Code(status int) codes.Code {
+	if status >= 200 && status < 300 {
+		return codes.OK
+	}
+	if c, ok := grpcCode[status]; ok {
+		return c
+	}
+	return codes.Unknown
+}
+
+// grpcCoder is implemented by errors which carry a gRPC code. Like httperr's
+// statusCoder, it is not exported, but is considered part of the stable
+// public API.
+//
+//  type grpcCoder interface {
+//      GRPCCode() codes.Code
+//  }
+type grpcCoder interface {
+	GRPCCode() codes.Code
+}
+
+type grpcError struct {
+	error
+	code codes.Code
+}
+
+var _ grpcCoder = &grpcError{}
+
+func (e *grpcError) Cause() error {
+	return e.error
+}
+
+// Unwrap returns the wrapped error.
+func (e *grpcError) Unwrap() error {
+	return e.error
+}
+
+// GRPCCode returns the error's gRPC code.
+func (e *grpcError) GRPCCode() codes.Code {
+	return e.code
+}
+
+// Wrap bundles an existing error with gRPC code c, and the corresponding HTTP
+// status code. This is useful for errors returned by gRPC clients:
+//
+//  return grpcerr.Wrap(status.Code(err), err)
+func Wrap(c codes.Code, err error, opts ...httperr.Option) error {
+	return &grpcError{
+		error: httperr.Wrap(HTTPStatus(c), err, opts...),
+		code:  c,
+	}
+}
+
+// New returns a new error with gRPC code c, and the corresponding HTTP status
+// code.
+func New(c codes.Code, msg string, opts ...httperr.Option) error {
+	return &grpcError{
+		error: httperr.New(HTTPStatus(c), msg, opts...),
+		code:  c,
+	}
+}
+
+// GRPCCode returns the gRPC code of err. If the outermost error in err's chain
+// which carries a status code was created by this package, its gRPC code is
+// returned. Otherwise, the code corresponding to err's HTTP status code is
+// returned. If err is nil, codes.OK is returned.
+func GRPCCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if coder, ok := e.(grpcCoder); ok {
+			return coder.GRPCCode()
+		}
+		if _, ok := e.(interface{ StatusCode() int }); ok {
+			break
+		}
+	}
+	return Code(httperr.StatusCode(err))
+}
@@ -0,0 +1,123 @@
+package grpcerr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/flimzy/juniper/httperr"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code     codes.Code
+		expected int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.Canceled, 499},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.DataLoss, http.StatusInternalServerError},
+		{codes.Code(99), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.code.String(), func(t *testing.T) {
+			if status := HTTPStatus(test.code); status != test.expected {
+				t.Errorf("Unexpected status: %d", status)
+			}
+		})
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected codes.Code
+	}{
+		{http.StatusOK, codes.OK},
+		{http.StatusNoContent, codes.OK},
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusUnauthorized, codes.Unauthenticated},
+		{http.StatusConflict, codes.Aborted},
+		{499, codes.Canceled},
+		{http.StatusServiceUnavailable, codes.Unavailable},
+		{http.StatusTeapot, codes.Unknown},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.status), func(t *testing.T) {
+			if c := Code(test.status); c != test.expected {
+				t.Errorf("Unexpected code: %s", c)
+			}
+		})
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		code     codes.Code
+		status   int
+		expected string
+	}{
+		{
+			name: "nil",
+			code: codes.OK,
+		},
+		{
+			name:     "new",
+			err:      New(codes.AlreadyExists, "user exists"),
+			code:     codes.AlreadyExists,
+			status:   http.StatusConflict,
+			expected: "user exists",
+		},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("creating user: %w", Wrap(codes.DataLoss, errors.New("corrupt"))),
+			code:     codes.DataLoss,
+			status:   http.StatusInternalServerError,
+			expected: "creating user: corrupt",
+		},
+		{
+			name:     "httperr",
+			err:      httperr.New(http.StatusNotFound, "not found"),
+			code:     codes.NotFound,
+			status:   http.StatusNotFound,
+			expected: "not found",
+		},
+		{
+			name:     "overridden by httperr",
+			err:      httperr.Wrap(http.StatusServiceUnavailable, New(codes.Internal, "oops")),
+			code:     codes.Unavailable,
+			status:   http.StatusServiceUnavailable,
+			expected: "oops",
+		},
+		{
+			name:     "plain error",
+			err:      errors.New("foo"),
+			code:     codes.Internal,
+			status:   http.StatusInternalServerError,
+			expected: "foo",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if c := GRPCCode(test.err); c != test.code {
+				t.Errorf("Unexpected code: %s", c)
+			}
+			if status := httperr.StatusCode(test.err); status != test.status {
+				t.Errorf("Unexpected status: %d", status)
+			}
+			if test.err != nil && test.err.Error() != test.expected {
+				t.Errorf("Unexpected message: %s", test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package httperr
+
+import (
+	"context"
+	"net/http"
+)
+
+// ErrorResponder serves a response for err, which is never nil.
+type ErrorResponder func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultResponder is the ErrorResponder used by HandlerFunc and
+// MiddlewareFunc when the request context carries none. It serves errors with
+// HandleRequestError.
+var DefaultResponder ErrorResponder = func(w http.ResponseWriter, r *http.Request, err error) {
+	_ = HandleRequestError(w, r, err)
+}
+
+type contextKey struct {
+	name string
+}
+
+// responderContextKey is a context key used to fetch the ErrorResponder from a
+// context. The returned value is of type ErrorResponder
+var responderContextKey = &contextKey{"responder"}
+
+// WithResponder returns a copy of r whose context carries responder, which is
+// then used to serve errors returned by HandlerFunc and MiddlewareFunc
+// handlers of the request. Middlewares, such as the view middleware, use this
+// to render errors in their own way.
+func WithResponder(r *http.Request, responder ErrorResponder) *http.Request {
+	ctx := context.WithValue(r.Context(), responderContextKey, responder)
+	return r.WithContext(ctx)
+}
+
+// Respond serves err with the ErrorResponder found in the request context, or
+// DefaultResponder if there is none. If err is nil, this function is a no-op.
+func Respond(w http.ResponseWriter, r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+	if responder, ok := r.Context().Value(responderContextKey).(ErrorResponder); ok {
+		responder(w, r, err)
+		return
+	}
+	DefaultResponder(w, r, err)
+}
+
+// HandlerFunc is an http handler which returns an error. Returned errors are
+// served by Respond, so that handlers need not handle errors themselves:
+//
+//  r.Get("/user/{id}", httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+//      user, err := loadUser(chi.URLParam(r, "id"))
+//      if err != nil {
+//          return err
+//      }
+//      return json.NewEncoder(w).Encode(user)
+//  }))
+type HandlerFunc func(http.ResponseWriter, *http.Request) error
+
+var _ http.Handler = HandlerFunc(nil)
+
+// ServeHTTP calls f(w, r), and serves any returned error with Respond.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Respond(w, r, f(w, r))
+}
+
+// MiddlewareFunc is an http middleware which returns an error. The middleware
+// calls next to continue processing the request. Returned errors are served by
+// Respond.
+type MiddlewareFunc func(w http.ResponseWriter, r *http.Request, next http.Handler) error
+
+// Middleware adapts f to the standard middleware signature:
+//
+//  r.Use(httperr.MiddlewareFunc(auth).Middleware)
+func (f MiddlewareFunc) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Respond(w, r, f(w, r, next))
+	})
+}
@@ -0,0 +1,94 @@
+package httperr
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		req     *http.Request
+		status  int
+		body    string
+	}{
+		{
+			name: "no error",
+			handler: HandlerFunc(func(w http.ResponseWriter, _ *http.Request) error {
+				_, err := w.Write([]byte("ok"))
+				return err
+			}),
+			status: http.StatusOK,
+			body:   "ok",
+		},
+		{
+			name: "error",
+			handler: HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+				return New(http.StatusNotFound, "no such user")
+			}),
+			status: http.StatusNotFound,
+			body:   "Error 404: no such user",
+		},
+		{
+			name: "custom responder",
+			handler: HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+				return errors.New("foo")
+			}),
+			req: WithResponder(httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, _ *http.Request, err error) {
+				w.WriteHeader(http.StatusTeapot)
+				_, _ = w.Write([]byte("custom: " + err.Error()))
+			}),
+			status: http.StatusTeapot,
+			body:   "custom: foo",
+		},
+		{
+			name: "middleware continues",
+			handler: MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) error {
+				next.ServeHTTP(w, r)
+				return nil
+			}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("next"))
+			})),
+			status: http.StatusOK,
+			body:   "next",
+		},
+		{
+			name: "middleware error",
+			handler: MiddlewareFunc(func(_ http.ResponseWriter, _ *http.Request, _ http.Handler) error {
+				return New(http.StatusForbidden, "go away")
+			}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("next"))
+			})),
+			status: http.StatusForbidden,
+			body:   "Error 403: go away",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := test.req
+			if req == nil {
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+			}
+			test.handler.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != test.status {
+				t.Errorf("Unexpected status code: %d", res.StatusCode)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package httperr
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithHeader adds a response header to the error, which is sent by
+// HandleError and HandleRequestError. It may be passed more than once to add
+// several values.
+func WithHeader(key, value string) Option {
+	return func(e *statusError) {
+		if e.header == nil {
+			e.header = make(http.Header)
+		}
+		e.header.Add(key, value)
+	}
+}
+
+// Headers returns the response headers attached to the errors in err's chain.
+// Where errors in the chain set the same header, the outermost error's values
+// are used. If no headers are attached, nil is returned.
+func Headers(err error) http.Header {
+	var header http.Header
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		se, ok := e.(*statusError)
+		if !ok {
+			continue
+		}
+		for key, values := range se.header {
+			if header == nil {
+				header = make(http.Header)
+			}
+			if _, ok := header[key]; !ok {
+				header[key] = append([]string(nil), values...)
+			}
+		}
+	}
+	return header
+}
+
+// setHeaders copies the headers attached to err to w.
+func setHeaders(w http.ResponseWriter, err error) {
+	for key, values := range Headers(err) {
+		w.Header()[key] = values
+	}
+}
+
+// retryAfter formats d as a Retry-After header value, in whole seconds.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Unauthorized returns a 401 error, with a WWW-Authenticate header for each
+// challenge, such as `Basic realm="example"`.
+func Unauthorized(msg string, challenges ...string) error {
+	opts := make([]Option, 0, len(challenges))
+	for _, c := range challenges {
+		opts = append(opts, WithHeader("WWW-Authenticate", c))
+	}
+	return New(http.StatusUnauthorized, msg, opts...)
+}
+
+// MethodNotAllowed returns a 405 error, with an Allow header listing the
+// allowed methods.
+func MethodNotAllowed(allowed ...string) error {
+	return New(http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed),
+		WithHeader("Allow", strings.Join(allowed, ", ")))
+}
+
+// TooManyRequests returns a 429 error, with a Retry-After header.
+func TooManyRequests(retryAfterDuration time.Duration) error {
+	return New(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests),
+		WithHeader("Retry-After", retryAfter(retryAfterDuration)))
+}
+
+// ServiceUnavailable returns a 503 error, with a Retry-After header.
+func ServiceUnavailable(retryAfterDuration time.Duration) error {
+	return New(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable),
+		WithHeader("Retry-After", retryAfter(retryAfterDuration)))
+}
+
+// Redirect returns an error with the given 3xx status, and a Location header.
+func Redirect(status int, location string) error {
+	return New(status, http.StatusText(status), WithHeader("Location", location))
+}
@@ -0,0 +1,152 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flimzy/diff"
+)
+
+func TestHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected http.Header
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "no headers",
+			err:  New(http.StatusNotFound, "not found"),
+		},
+		{
+			name: "multiple values",
+			err:  New(http.StatusNotFound, "not found", WithHeader("x-foo", "a"), WithHeader("X-Foo", "b")),
+			expected: http.Header{
+				"X-Foo": []string{"a", "b"},
+			},
+		},
+		{
+			name: "merged through chain",
+			err: fmt.Errorf("outer: %w", Wrap(http.StatusServiceUnavailable,
+				New(http.StatusTooManyRequests, "inner", WithHeader("Retry-After", "10"), WithHeader("X-Inner", "1")),
+				WithHeader("Retry-After", "20"),
+			)),
+			expected: http.Header{
+				"Retry-After": []string{"20"},
+				"X-Inner":     []string{"1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Headers(test.err)
+			if d := diff.Interface(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestHeaderHelpers(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		header http.Header
+	}{
+		{
+			name:   "Unauthorized",
+			err:    Unauthorized("login required", `Basic realm="example"`, `Bearer realm="example"`),
+			status: http.StatusUnauthorized,
+			header: http.Header{
+				"Www-Authenticate": []string{`Basic realm="example"`, `Bearer realm="example"`},
+			},
+		},
+		{
+			name:   "MethodNotAllowed",
+			err:    MethodNotAllowed(http.MethodGet, http.MethodPost),
+			status: http.StatusMethodNotAllowed,
+			header: http.Header{
+				"Allow": []string{"GET, POST"},
+			},
+		},
+		{
+			name:   "TooManyRequests",
+			err:    TooManyRequests(1500 * time.Millisecond),
+			status: http.StatusTooManyRequests,
+			header: http.Header{
+				"Retry-After": []string{"2"},
+			},
+		},
+		{
+			name:   "ServiceUnavailable",
+			err:    ServiceUnavailable(time.Minute),
+			status: http.StatusServiceUnavailable,
+			header: http.Header{
+				"Retry-After": []string{"60"},
+			},
+		},
+		{
+			name:   "Redirect",
+			err:    Redirect(http.StatusFound, "/login"),
+			status: http.StatusFound,
+			header: http.Header{
+				"Location": []string{"/login"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := StatusCode(test.err); status != test.status {
+				t.Errorf("Unexpected status: %d", status)
+			}
+			if d := diff.Interface(test.header, Headers(test.err)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestHandleErrorHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+	}{
+		{name: "plain text"},
+		{name: "problem json", accept: ContentTypeProblemJSON},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", test.accept)
+			err := fmt.Errorf("wrapped: %w", TooManyRequests(time.Second))
+			if e := HandleRequestError(w, r, err); e != nil {
+				t.Fatal(e)
+			}
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("Unexpected status code: %d", res.StatusCode)
+			}
+			if ra := res.Header.Get("Retry-After"); ra != "1" {
+				t.Errorf("Unexpected Retry-After: %q", ra)
+			}
+		})
+	}
+	t.Run("standard error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if e := HandleError(w, errors.New("foo")); e != nil {
+			t.Fatal(e)
+		}
+		if d := diff.Interface(http.Header{}, w.Result().Header); d != nil {
+			t.Error(d)
+		}
+	})
+}
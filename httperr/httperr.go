@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/pkg/errors"
+
+	"github.com/flimzy/juniper/donewriter"
 )
 
 // copies from github.com/pkg/errors package
@@ -15,26 +17,58 @@ type causer interface {
 type statusError struct {
 	error
 	status int
+	// problem details, set by options
+	typeURI    string
+	instance   string
+	extensions map[string]interface{}
+	// public is the client-safe message, set by WithPublicMessage
+	public string
+	// header holds response headers, set by WithHeader
+	header http.Header
+	// code is the application error code, set by WithCode
+	code string
 }
 
 var _ error = &statusError{}
 var _ causer = &statusError{}
 var _ statusCoder = &statusError{}
+var _ errorCoder = &statusError{}
 
 func (e *statusError) Cause() error {
 	return e.error
 }
 
+// Unwrap returns the wrapped error.
+func (e *statusError) Unwrap() error {
+	return e.error
+}
+
+// Is reports whether target is the sentinel error for e's status code, so that
+// errors.Is(err, ErrNotFound) is true for any error wrapped with status 404.
+func (e *statusError) Is(target error) bool {
+	return isStatus(e.status, target)
+}
+
 func (e *statusError) StatusCode() int {
 	return e.status
 }
 
-// Wrap bundles an existing error with a status code.
-func Wrap(status int, err error) error {
-	return &statusError{
+// ErrorCode returns the application error code, if any.
+func (e *statusError) ErrorCode() string {
+	return e.code
+}
+
+// Wrap bundles an existing error with a status code. Options may be passed
+// to attach additional details to the error.
+func Wrap(status int, err error, opts ...Option) error {
+	e := &statusError{
 		error:  err,
 		status: status,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // Wrapf bundles an existing error with a status code and adds a message.
@@ -42,9 +76,10 @@ func Wrapf(status int, err error, fmt string, args ...interface{}) error {
 	return Wrap(status, errors.Wrapf(err, fmt, args...))
 }
 
-// New returns a new status-embedded error.
-func New(status int, msg string) error {
-	return Wrap(status, errors.New(msg))
+// New returns a new status-embedded error. Options may be passed to attach
+// additional details to the error.
+func New(status int, msg string, opts ...Option) error {
+	return Wrap(status, errors.New(msg), opts...)
 }
 
 // Errorf returns a new status-embedded error with formatting.
@@ -56,9 +91,34 @@ type statusCoder interface {
 	StatusCode() int
 }
 
-// StatusCode returns the HTTP status code embedded in the error, or 500
-// (internal server error), if there was no specified status code.  If err is
-// nil, StatusCode returns 0.
+// Precedence determines which status code is used when an error chain
+// contains more than one error with an embedded status code.
+type Precedence int
+
+const (
+	// Outermost gives precedence to the status code closest to the top of the
+	// error chain, so that callers may override the status of the errors they
+	// wrap.
+	Outermost Precedence = iota
+	// Innermost gives precedence to the status code closest to the root cause
+	// of the error.
+	Innermost
+)
+
+// DefaultPrecedence is the Precedence used by StatusCode. It is the default for
+// Config.Precedence.
+var DefaultPrecedence = Outermost
+
+// StatusCode returns the HTTP status code embedded in the error. If there was
+// no specified status code, the status is determined by the registered
+// classifiers (see RegisterClassifier), or is 500 (internal server error) if
+// none recognizes the error.  If err is nil, StatusCode returns 0.
+//
+// The entire error chain is searched, by way of errors.As, so errors wrapped
+// with fmt.Errorf's %w verb or github.com/pkg/errors retain their status code.
+// If more than one error in the chain embeds a status code, DefaultPrecedence
+// determines which is used. The status code of an aggregate error, such as
+// those returned by Join or errors.Join, is computed from its members.
 //
 // This method uses the statusCoder interface, which is not exported by this
 // package, but is considered part of the stable public API.  Driver
@@ -69,25 +129,137 @@ type statusCoder interface {
 //      StatusCode() int
 //  }
 func StatusCode(err error) int {
+	return DefaultConfig().StatusCode(err)
+}
+
+// StatusCodePrecedence works as StatusCode, but uses precedence p rather than
+// DefaultPrecedence.
+func StatusCodePrecedence(err error, p Precedence) int {
+	return DefaultConfig().statusCode(err, p)
+}
+
+// StatusCode works as the package-level StatusCode, but uses c.Precedence,
+// and c.StatusPolicy for aggregate errors.
+func (c *Config) StatusCode(err error) int {
+	return c.statusCode(err, c.Precedence)
+}
+
+func (c *Config) statusCode(err error, p Precedence) int {
 	if err == nil {
 		return 0
 	}
-	if coder, ok := err.(statusCoder); ok {
-		return coder.StatusCode()
+	if m := findMulti(err); m != nil {
+		return c.multiStatusCode(m)
+	}
+	var coder statusCoder
+	if !errors.As(err, &coder) {
+		return classify(err)
+	}
+	if p == Innermost {
+		for {
+			var inner statusCoder
+			next := errors.Unwrap(coder.(error))
+			if next == nil || !errors.As(next, &inner) {
+				break
+			}
+			coder = inner
+		}
 	}
-	return http.StatusInternalServerError
+	if m, ok := coder.(*MultiError); ok {
+		return c.multiStatusCode(m)
+	}
+	return coder.StatusCode()
 }
 
 // HandleError serves an error response if e is non-nil. If the error embeds a
 // status code via the statusCoder interface, If the response cannot
 // be written, for instance if the response has already been sent, an error is
 // returned. If e is nil, this function is a no-op.
+//
+// The response contains the error's public message, if one was attached with
+// WithPublicMessage. In Production mode, the messages of 5xx errors are
+// replaced by the generic status text and a correlation ID.
+//
+// If the error has an application error code, it is included in the response
+// as "Error <status> [<code>]: <message>".
+//
+// If e's chain contains a *ValidationError, the response is served as
+// application/problem+json, with the field errors listed in the "errors"
+// member.
+//
+// If DefaultReporter is set, the error is reported to it.
+//
+// If w is a donewriter.DoneWriter to which a response has already been
+// written, the error is logged to ErrorLog and reported, but not written, and
+// ErrResponseStarted is returned. If AbortStartedResponse is true, the handler
+// is then aborted instead.
+//
+// HandleError uses the settings of DefaultConfig. To use other settings, call
+// Config.HandleError.
 func HandleError(w http.ResponseWriter, e error) error {
+	return DefaultConfig().handleError(w, nil, e)
+}
+
+// HandleError works as the package-level HandleError, but uses the settings
+// of c.
+func (c *Config) HandleError(w http.ResponseWriter, e error) error {
+	return c.handleError(w, nil, e)
+}
+
+func (c *Config) handleError(w http.ResponseWriter, r *http.Request, e error) error {
 	if e == nil {
 		return nil
 	}
-	status := StatusCode(e)
+	if done, _ := donewriter.WriterIsDone(w); done {
+		return c.responseStarted(r, e)
+	}
+	var verr *ValidationError
+	if errors.As(e, &verr) {
+		return c.writeProblem(w, r, e, ContentTypeProblemJSON)
+	}
+	return c.writeText(w, r, e)
+}
+
+// ErrResponseStarted is returned by HandleError and HandleRequestError when
+// an error cannot be served, because a response has already been written.
+var ErrResponseStarted = errors.New("httperr: response already started")
+
+// AbortStartedResponse, when true, causes HandleError and HandleRequestError
+// to panic with http.ErrAbortHandler after logging an error which cannot be
+// served because a response has already been written. The server then closes
+// the connection, or resets the stream, so that clients do not mistake the
+// truncated response for a successful one. It is the default for
+// Config.AbortStartedResponse.
+var AbortStartedResponse = false
+
+// responseStarted logs and reports e, which cannot be served because a
+// response has already been written.
+func (c *Config) responseStarted(r *http.Request, e error) error {
+	status := c.StatusCode(e)
+	c.logf("Error after response was sent: %d %s", status, e)
+	c.report(r, e, status, "")
+	if c.AbortStartedResponse {
+		panic(http.ErrAbortHandler)
+	}
+	return ErrResponseStarted
+}
+
+// writeText serves e as a plain text response.
+func (c *Config) writeText(w http.ResponseWriter, r *http.Request, e error) error {
+	status := c.StatusCode(e)
+	msg, id := c.clientMessage(w, r, e, status)
+	setHeaders(w, e)
 	w.WriteHeader(status)
-	_, err := fmt.Fprintf(w, "Error %d: %s", status, e)
+	prefix := fmt.Sprintf("Error %d", status)
+	if code := Code(e); code != "" {
+		prefix += " [" + code + "]"
+	}
+	var err error
+	if id != "" {
+		_, err = fmt.Fprintf(w, "%s: %s (correlation ID: %s)", prefix, msg, id)
+	} else {
+		_, err = fmt.Fprintf(w, "%s: %s", prefix, msg)
+	}
+	c.report(r, e, status, id)
 	return err
 }
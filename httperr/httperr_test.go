@@ -1,14 +1,20 @@
 package httperr
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/flimzy/diff"
 	"github.com/flimzy/testy"
+	pkgerrors "github.com/pkg/errors"
+
+	"github.com/flimzy/juniper/donewriter"
 )
 
 func TestStatusCoder(t *testing.T) {
@@ -27,6 +33,31 @@ func TestStatusCoder(t *testing.T) {
 			err:      errors.New("foo"),
 			expected: 500,
 		},
+		{
+			name:     "status error",
+			err:      New(http.StatusNotFound, "not found"),
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "wrapped with %w",
+			err:      fmt.Errorf("loading user: %w", New(http.StatusNotFound, "no such user")),
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "wrapped with pkg/errors",
+			err:      pkgerrors.Wrap(New(http.StatusNotFound, "no such user"), "loading user"),
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "outermost wins",
+			err:      Wrap(http.StatusBadRequest, fmt.Errorf("loading user: %w", New(http.StatusNotFound, "no such user"))),
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "sentinel",
+			err:      fmt.Errorf("loading user: %w", ErrForbidden),
+			expected: http.StatusForbidden,
+		},
 		// {
 		// 	name:     "StatusCoder",
 		// 	err:      kerrors.Status(400, "bad request"),
@@ -45,6 +76,154 @@ func TestStatusCoder(t *testing.T) {
 	}
 }
 
+func TestStatusCodePrecedence(t *testing.T) {
+	err := Wrap(http.StatusBadRequest, fmt.Errorf("loading user: %w",
+		Wrapf(http.StatusNotFound, errors.New("sql: no rows"), "no such user")))
+	tests := []struct {
+		name       string
+		err        error
+		precedence Precedence
+		expected   int
+	}{
+		{
+			name:       "nil",
+			precedence: Innermost,
+			expected:   0,
+		},
+		{
+			name:       "no status",
+			err:        errors.New("foo"),
+			precedence: Innermost,
+			expected:   http.StatusInternalServerError,
+		},
+		{
+			name:       "outermost",
+			err:        err,
+			precedence: Outermost,
+			expected:   http.StatusBadRequest,
+		},
+		{
+			name:       "innermost",
+			err:        err,
+			precedence: Innermost,
+			expected:   http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := StatusCodePrecedence(test.err, test.precedence)
+			if result != test.expected {
+				t.Errorf("Unexpected result. Expected %d, got %d", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	cause := errors.New("cause")
+	err := Wrap(http.StatusNotFound, cause)
+	if !errors.Is(err, cause) {
+		t.Error("Expected errors.Is to find the cause")
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		target   error
+		expected bool
+	}{
+		{
+			name:     "same status",
+			err:      New(http.StatusNotFound, "no such user"),
+			target:   ErrNotFound,
+			expected: true,
+		},
+		{
+			name:     "different status",
+			err:      New(http.StatusNotFound, "no such user"),
+			target:   ErrForbidden,
+			expected: false,
+		},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("loading user: %w", Errorf(http.StatusForbidden, "user %d is private", 123)),
+			target:   ErrForbidden,
+			expected: true,
+		},
+		{
+			// Sentinels match any status in the chain, regardless of
+			// precedence.
+			name:     "inner status",
+			err:      Wrap(http.StatusBadRequest, New(http.StatusNotFound, "no such user")),
+			target:   ErrNotFound,
+			expected: true,
+		},
+		{
+			name:     "sentinel itself",
+			err:      fmt.Errorf("oops: %w", ErrGone),
+			target:   ErrGone,
+			expected: true,
+		},
+		{
+			name:     "standard error",
+			err:      errors.New("foo"),
+			target:   ErrInternalServerError,
+			expected: false,
+		},
+		{
+			name:     "validation error",
+			err:      (&ValidationError{}).Add("name", "required", "is required"),
+			target:   ErrUnprocessable,
+			expected: true,
+		},
+		{
+			name:     "validation error with status",
+			err:      &ValidationError{Status: http.StatusBadRequest},
+			target:   ErrBadRequest,
+			expected: true,
+		},
+		{
+			name:     "panic error",
+			err:      &PanicError{Value: "boom"},
+			target:   ErrInternalServerError,
+			expected: true,
+		},
+		{
+			name:     "problem",
+			err:      fmt.Errorf("upstream: %w", &Problem{Status: http.StatusConflict}),
+			target:   ErrConflict,
+			expected: true,
+		},
+		{
+			name:     "problem different status",
+			err:      &Problem{Status: http.StatusConflict},
+			target:   ErrNotFound,
+			expected: false,
+		},
+		{
+			name:     "multi error",
+			err:      Join(New(http.StatusNotFound, "a"), New(http.StatusBadGateway, "b")),
+			target:   ErrBadGateway,
+			expected: true,
+		},
+		{
+			name:     "multi error standard members",
+			err:      Join(errors.New("a"), errors.New("b")),
+			target:   ErrInternalServerError,
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := errors.Is(test.err, test.target); result != test.expected {
+				t.Errorf("Unexpected result: %t", result)
+			}
+		})
+	}
+}
+
 func TestHandleError(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -91,3 +270,72 @@ func TestHandleError(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleErrorStarted(t *testing.T) {
+	defer func(l *log.Logger, r Reporter) {
+		ErrorLog, DefaultReporter = l, r
+	}(ErrorLog, DefaultReporter)
+	logBuf := &bytes.Buffer{}
+	ErrorLog = log.New(logBuf, "", 0)
+	reporter := &MemoryReporter{}
+	DefaultReporter = reporter
+
+	for _, name := range []string{"HandleError", "HandleRequestError", "WriteDebugPage"} {
+		t.Run(name, func(t *testing.T) {
+			logBuf.Reset()
+			reporter.Reset()
+			rec := httptest.NewRecorder()
+			w := donewriter.New(rec)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("partial"))
+			e := New(http.StatusBadGateway, "upstream failed")
+			var err error
+			switch name {
+			case "HandleError":
+				err = HandleError(w, e)
+			case "HandleRequestError":
+				err = HandleRequestError(w, httptest.NewRequest(http.MethodGet, "/", nil), e)
+			default:
+				err = WriteDebugPage(w, httptest.NewRequest(http.MethodGet, "/", nil), e)
+			}
+			if !errors.Is(err, ErrResponseStarted) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if d := diff.Text("partial", rec.Body.String()); d != nil {
+				t.Error(d)
+			}
+			if rec.Code != http.StatusOK {
+				t.Errorf("Unexpected status: %d", rec.Code)
+			}
+			if d := diff.Text("Error after response was sent: 502 upstream failed\n", logBuf.String()); d != nil {
+				t.Error(d)
+			}
+			if n := len(reporter.Reports()); n != 1 {
+				t.Errorf("Expected 1 report, got %d", n)
+			}
+		})
+	}
+	t.Run("abort", func(t *testing.T) {
+		defer func(a bool) {
+			AbortStartedResponse = a
+		}(AbortStartedResponse)
+		AbortStartedResponse = true
+		w := donewriter.New(httptest.NewRecorder())
+		_, _ = w.Write([]byte("partial"))
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("Unexpected panic: %v", r)
+			}
+		}()
+		_ = HandleError(w, errors.New("foo"))
+	})
+	t.Run("not started", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		if err := HandleError(donewriter.New(rec), errors.New("foo")); err != nil {
+			t.Fatal(err)
+		}
+		if d := diff.Text("Error 500: foo", rec.Body.String()); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package httperr
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StatusPolicy computes the status code of an aggregate error from the status
+// codes of its members, in order. statuses is never empty.
+type StatusPolicy func(statuses []int) int
+
+// MostSevere is a StatusPolicy which selects the most frequent status code of
+// the most severe status class present, so that any 5xx error takes
+// precedence over 4xx errors. Ties are resolved in favor of the earliest
+// member.
+func MostSevere(statuses []int) int {
+	class := 0
+	for _, status := range statuses {
+		if status/100 > class {
+			class = status / 100
+		}
+	}
+	var inClass []int
+	for _, status := range statuses {
+		if status/100 == class {
+			inClass = append(inClass, status)
+		}
+	}
+	return MostFrequent(inClass)
+}
+
+// MostFrequent is a StatusPolicy which selects the most frequent status code.
+// Ties are resolved in favor of the earliest member.
+func MostFrequent(statuses []int) int {
+	counts := make(map[int]int, len(statuses))
+	for _, status := range statuses {
+		counts[status]++
+	}
+	best := statuses[0]
+	for _, status := range statuses {
+		if counts[status] > counts[best] {
+			best = status
+		}
+	}
+	return best
+}
+
+// First is a StatusPolicy which selects the status code of the first member.
+func First(statuses []int) int {
+	return statuses[0]
+}
+
+// DefaultStatusPolicy is the StatusPolicy used by aggregate errors with no
+// policy of their own, including those created by the standard library's
+// errors.Join. It is the default for Config.StatusPolicy.
+var DefaultStatusPolicy StatusPolicy = MostSevere
+
+// MultiError is an aggregate of several errors, such as the failures
+// collected when fanning out a request to several backends. Like the errors
+// returned by the standard library's errors.Join, its message is the
+// messages of its members, separated by newlines, and errors.Is and errors.As
+// consider each of its members.
+//
+// Its status code is computed from the status codes of its members by
+// Policy. When served, all member messages are included in the response: as
+// separate lines of plain text responses, and in the "errors" member of
+// problem details, along with each member's status and code.
+type MultiError struct {
+	// Errors are the members of the aggregate.
+	Errors []error
+	// Policy computes the status code of the aggregate. If nil,
+	// DefaultStatusPolicy is used.
+	Policy StatusPolicy
+}
+
+var _ error = &MultiError{}
+var _ statusCoder = &MultiError{}
+
+// MemberError describes a member of an aggregate error in problem details.
+type MemberError struct {
+	Status  int    `json:"status" xml:"status"`
+	Code    string `json:"code,omitempty" xml:"code,omitempty"`
+	Message string `json:"message" xml:"message"`
+}
+
+// Join returns a MultiError of the non-nil errors in errs, using
+// DefaultStatusPolicy. Members which are themselves aggregates, created by
+// Join or by errors.Join, are flattened. If there are no non-nil errors, Join
+// returns nil.
+func Join(errs ...error) error {
+	return JoinPolicy(nil, errs...)
+}
+
+// JoinPolicy works as Join, but computes the status code with policy p.
+func JoinPolicy(p StatusPolicy, errs ...error) error {
+	m := &MultiError{Policy: p}
+	for _, err := range errs {
+		m.Errors = append(m.Errors, flatten(err)...)
+	}
+	if len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+// flatten returns the members of err, if it is an aggregate with no policy of
+// its own, or err itself.
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if m, ok := err.(*MultiError); ok && m.Policy != nil {
+		return []error{err}
+	}
+	members, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range members.Unwrap() {
+		errs = append(errs, flatten(e)...)
+	}
+	return errs
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the members of the aggregate.
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
+
+// StatusCode returns the status code computed by the aggregate's policy, or
+// 500 if it has no members.
+func (m *MultiError) StatusCode() int {
+	return DefaultConfig().multiStatusCode(m)
+}
+
+func (c *Config) multiStatusCode(m *MultiError) int {
+	if len(m.Errors) == 0 {
+		return http.StatusInternalServerError
+	}
+	p := m.Policy
+	if p == nil {
+		p = c.statusPolicy()
+	}
+	statuses := make([]int, len(m.Errors))
+	for i, err := range m.Errors {
+		statuses[i] = c.StatusCode(err)
+	}
+	return p(statuses)
+}
+
+// Is returns true if target is the sentinel error for the aggregate's status
+// code. Members are matched by errors.Is through Unwrap.
+func (m *MultiError) Is(target error) bool {
+	return isStatus(m.StatusCode(), target)
+}
+
+// findMulti returns the first aggregate error in err's chain, if it is reached
+// before any other error with an embedded status code. Aggregates created by
+// errors.Join are returned as a MultiError with no policy.
+func findMulti(err error) *MultiError {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if m, ok := e.(*MultiError); ok {
+			return m
+		}
+		if members, ok := e.(interface{ Unwrap() []error }); ok {
+			return &MultiError{Errors: members.Unwrap()}
+		}
+		if _, ok := e.(statusCoder); ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// memberErrors describes each member of an aggregate for problem details.
+func (c *Config) memberErrors(errs []error) []MemberError {
+	members := make([]MemberError, len(errs))
+	for i, err := range errs {
+		members[i] = MemberError{
+			Status:  c.StatusCode(err),
+			Code:    Code(err),
+			Message: c.memberMessage(err),
+		}
+	}
+	return members
+}
+
+// memberMessage returns the client-safe message of a member of an aggregate.
+// In Production mode, the messages of 5xx errors with no public message are
+// replaced by the generic status text.
+func (c *Config) memberMessage(err error) string {
+	if msg, ok := c.publicMessage(err); ok {
+		return msg
+	}
+	if status := c.StatusCode(err); c.Production && status >= http.StatusInternalServerError {
+		return http.StatusText(status)
+	}
+	return err.Error()
+}
+
+// multiPublicMessage returns the client-safe messages of the members of an
+// aggregate, separated by newlines. If every member would be hidden in
+// Production mode, false is returned, so that the aggregate is hidden as a
+// whole.
+func (c *Config) multiPublicMessage(errs []error) (string, bool) {
+	msgs := make([]string, len(errs))
+	hidden := 0
+	for i, err := range errs {
+		if _, ok := c.publicMessage(err); !ok && c.Production && c.StatusCode(err) >= http.StatusInternalServerError {
+			hidden++
+		}
+		msgs[i] = c.memberMessage(err)
+	}
+	if hidden == len(errs) {
+		return "", false
+	}
+	return strings.Join(msgs, "\n"), true
+}
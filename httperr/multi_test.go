@@ -0,0 +1,186 @@
+package httperr
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestStatusPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   StatusPolicy
+		statuses []int
+		expected int
+	}{
+		{"most severe, 5xx wins", MostSevere, []int{404, 404, 502}, 502},
+		{"most severe, most frequent 4xx", MostSevere, []int{400, 404, 404}, 404},
+		{"most severe, tie", MostSevere, []int{409, 404, 404, 409}, 409},
+		{"most frequent", MostFrequent, []int{404, 502, 404}, 404},
+		{"first", First, []int{404, 502}, 404},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := test.policy(test.statuses); status != test.expected {
+				t.Errorf("Unexpected status: %d", status)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	errNotFound := New(http.StatusNotFound, "user not found")
+	errGateway := New(http.StatusBadGateway, "backend down")
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		msg      string
+		members  int
+		contains []error
+	}{
+		{
+			name: "nil",
+			err:  Join(nil, nil),
+		},
+		{
+			name:     "single",
+			err:      Join(nil, errNotFound),
+			status:   http.StatusNotFound,
+			msg:      "user not found",
+			members:  1,
+			contains: []error{errNotFound},
+		},
+		{
+			name:     "5xx wins",
+			err:      Join(errNotFound, errGateway, New(http.StatusNotFound, "post not found")),
+			status:   http.StatusBadGateway,
+			msg:      "user not found\nbackend down\npost not found",
+			members:  3,
+			contains: []error{errNotFound, errGateway, ErrNotFound},
+		},
+		{
+			name:     "policy",
+			err:      JoinPolicy(MostFrequent, errNotFound, errGateway, errNotFound),
+			status:   http.StatusNotFound,
+			msg:      "user not found\nbackend down\nuser not found",
+			members:  3,
+			contains: []error{errGateway},
+		},
+		{
+			name:     "flattens errors.Join",
+			err:      Join(errors.Join(errNotFound, errGateway), Join(errors.New("plain"))),
+			status:   http.StatusBadGateway,
+			msg:      "user not found\nbackend down\nplain",
+			members:  3,
+			contains: []error{errNotFound, errGateway},
+		},
+		{
+			name:     "standard errors.Join",
+			err:      fmt.Errorf("fetching: %w", errors.Join(errNotFound, New(http.StatusBadRequest, "bad id"), errNotFound)),
+			status:   http.StatusNotFound,
+			msg:      "fetching: user not found\nbad id\nuser not found",
+			contains: []error{errNotFound},
+		},
+		{
+			name:    "wrapped with status",
+			err:     Wrap(http.StatusServiceUnavailable, Join(errNotFound, errGateway)),
+			status:  http.StatusServiceUnavailable,
+			msg:     "user not found\nbackend down",
+			members: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.status == 0 {
+				if test.err != nil {
+					t.Errorf("Unexpected error: %s", test.err)
+				}
+				return
+			}
+			if status := StatusCode(test.err); status != test.status {
+				t.Errorf("Unexpected status: %d", status)
+			}
+			if d := diff.Text(test.msg, test.err.Error()); d != nil {
+				t.Error(d)
+			}
+			var m *MultiError
+			if errors.As(test.err, &m) && test.members != len(m.Errors) {
+				t.Errorf("Unexpected member count: %d", len(m.Errors))
+			}
+			for _, target := range test.contains {
+				if !errors.Is(test.err, target) {
+					t.Errorf("Expected error to match %s", target)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleMultiError(t *testing.T) {
+	err := Join(
+		New(http.StatusNotFound, "user not found", WithCode("user.not_found")),
+		New(http.StatusBadGateway, "dial tcp 10.0.0.1: refused", WithPublicMessage("Billing unavailable")),
+		errors.New("db: connection reset"),
+	)
+	tests := []struct {
+		name        string
+		production  bool
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name: "plain text",
+			body: "Error 502: user not found\nBilling unavailable\ndb: connection reset",
+		},
+		{
+			name:       "plain text, production",
+			production: true,
+			body:       "Error 502: user not found\nBilling unavailable\nInternal Server Error",
+		},
+		{
+			name:        "json",
+			accept:      ContentTypeProblemJSON,
+			contentType: ContentTypeProblemJSON,
+			body:        `{"detail":"user not found\nBilling unavailable\ndb: connection reset","errors":[{"status":404,"code":"user.not_found","message":"user not found"},{"status":502,"message":"Billing unavailable"},{"status":500,"message":"db: connection reset"}],"status":502,"title":"Bad Gateway"}`,
+		},
+		{
+			name:        "xml",
+			accept:      ContentTypeProblemXML,
+			contentType: ContentTypeProblemXML,
+			body:        xml.Header + `<problem xmlns="urn:ietf:rfc:7807"><detail>user not found&#xA;Billing unavailable&#xA;db: connection reset</detail><errors><status>404</status><code>user.not_found</code><message>user not found</message></errors><errors><status>502</status><message>Billing unavailable</message></errors><errors><status>500</status><message>db: connection reset</message></errors><status>502</status><title>Bad Gateway</title></problem>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(p bool) {
+				Production = p
+			}(Production)
+			Production = test.production
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			_ = HandleRequestError(w, r, err)
+			res := w.Result()
+			if res.StatusCode != http.StatusBadGateway {
+				t.Errorf("Unexpected status: %d", res.StatusCode)
+			}
+			if ct := res.Header.Get("Content-Type"); test.contentType != "" && ct != test.contentType {
+				t.Errorf("Unexpected content type: %s", ct)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
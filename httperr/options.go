@@ -0,0 +1,31 @@
+package httperr
+
+// Option attaches additional details to an error created by New or Wrap.
+type Option func(*statusError)
+
+// WithType sets the problem type URI of the error, as reported in problem
+// detail responses.
+func WithType(uri string) Option {
+	return func(e *statusError) {
+		e.typeURI = uri
+	}
+}
+
+// WithInstance sets the URI identifying the specific occurrence of the
+// problem, as reported in problem detail responses.
+func WithInstance(uri string) Option {
+	return func(e *statusError) {
+		e.instance = uri
+	}
+}
+
+// WithExtension adds an extension member to the error, as reported in problem
+// detail responses.
+func WithExtension(key string, value interface{}) Option {
+	return func(e *statusError) {
+		if e.extensions == nil {
+			e.extensions = make(map[string]interface{})
+		}
+		e.extensions[key] = value
+	}
+}
@@ -0,0 +1,386 @@
+package httperr
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/flimzy/juniper/donewriter"
+)
+
+// Content types for problem detail responses, as defined by RFC 9457.
+const (
+	ContentTypeProblemJSON = "application/problem+json"
+	ContentTypeProblemXML  = "application/problem+xml"
+)
+
+// problemNamespace is the XML namespace for problem details.
+const problemNamespace = "urn:ietf:rfc:7807"
+
+// Problem is an error described by the problem details members of RFC 9457.
+type Problem struct {
+	// Type is a URI reference identifying the problem type. If empty,
+	// "about:blank" is implied.
+	Type string
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+	// Status is the HTTP status code.
+	Status int
+	// Detail is a human-readable explanation specific to this occurrence of
+	// the problem.
+	Detail string
+	// Instance is a URI reference identifying this occurrence of the problem.
+	Instance string
+	// Extensions are additional members of the problem details.
+	Extensions map[string]interface{}
+}
+
+var _ error = &Problem{}
+var _ statusCoder = &Problem{}
+var _ errorCoder = &Problem{}
+var _ json.Marshaler = &Problem{}
+var _ json.Unmarshaler = &Problem{}
+var _ xml.Marshaler = &Problem{}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// StatusCode returns the problem's status code, or 500 if it is unset.
+func (p *Problem) StatusCode() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// Is returns true if target is the sentinel error for p's status code.
+func (p *Problem) Is(target error) bool {
+	return isStatus(p.StatusCode(), target)
+}
+
+// ErrorCode returns the "code" extension member, if it is a string.
+func (p *Problem) ErrorCode() string {
+	code, _ := p.Extensions["code"].(string)
+	return code
+}
+
+// members returns the standard and extension members of the problem. The
+// standard members take precedence over extensions of the same name.
+func (p *Problem) members() map[string]interface{} {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for k, v := range map[string]string{"type": p.Type, "title": p.Title, "detail": p.Detail, "instance": p.Instance} {
+		if v != "" {
+			m[k] = v
+		} else {
+			delete(m, k)
+		}
+	}
+	m["status"] = p.StatusCode()
+	return m
+}
+
+// MarshalJSON marshals the problem as an application/problem+json object.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.members())
+}
+
+// UnmarshalJSON unmarshals an application/problem+json object. Members other
+// than the standard members are stored in Extensions.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for k, v := range members {
+		var ok bool
+		switch k {
+		case "type":
+			p.Type, ok = v.(string)
+		case "title":
+			p.Title, ok = v.(string)
+		case "detail":
+			p.Detail, ok = v.(string)
+		case "instance":
+			p.Instance, ok = v.(string)
+		case "status":
+			var status float64
+			status, ok = v.(float64)
+			p.Status = int(status)
+		default:
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]interface{})
+			}
+			p.Extensions[k] = v
+			ok = true
+		}
+		if !ok {
+			return errors.Errorf("invalid problem member '%s'", k)
+		}
+	}
+	return nil
+}
+
+// MarshalXML marshals the problem as an application/problem+xml document.
+// Extension values must be encodable by encoding/xml.
+func (p *Problem) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Space: problemNamespace, Local: "problem"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	members := p.members()
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := e.EncodeElement(members[k], xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return errors.Wrapf(err, "failed to encode problem member '%s'", k)
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// ToProblem converts err to a Problem. If err's chain contains a Problem, it
+// is copied, and otherwise the problem is built from err's public message. In
+// either case, the status code, application error code (as the "code"
+// extension member), and any details attached with options are taken from the
+// whole chain, so that they match the plain text response. The members of
+// aggregate errors, or the fields of validation errors, are listed in the
+// "errors" extension member. If err is nil, ToProblem returns nil.
+func ToProblem(err error) *Problem {
+	return DefaultConfig().ToProblem(err)
+}
+
+// ToProblem works as the package-level ToProblem, but uses the settings of c
+// to compute status codes and hide messages.
+func (c *Config) ToProblem(err error) *Problem {
+	if err == nil {
+		return nil
+	}
+	multi := findMulti(err)
+	status := c.StatusCode(err)
+	var p *Problem
+	if multi == nil && errors.As(err, &p) {
+		problem := *p
+		p = &problem
+		if status != p.StatusCode() {
+			p.Title = http.StatusText(status)
+			p.Status = status
+		}
+		if msg, ok := c.publicMessage(err); ok {
+			p.Detail = msg
+		}
+	} else {
+		p = &Problem{
+			Title:  http.StatusText(status),
+			Status: status,
+			Detail: c.PublicMessage(err),
+		}
+	}
+	// Walk the chain from the innermost error out, so that outer details
+	// replace inner ones.
+	var chain []*statusError
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if se, ok := e.(*statusError); ok {
+			chain = append(chain, se)
+		}
+	}
+	extensions := make(map[string]interface{}, len(p.Extensions))
+	for k, v := range p.Extensions {
+		extensions[k] = v
+	}
+	p.Extensions = extensions
+	var verr *ValidationError
+	if multi != nil {
+		p.Extensions["errors"] = c.memberErrors(multi.Errors)
+	} else if errors.As(err, &verr) {
+		p.Extensions["errors"] = verr.Fields
+	}
+	if code := Code(err); code != "" {
+		p.Extensions["code"] = code
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		se := chain[i]
+		if se.typeURI != "" {
+			p.Type = se.typeURI
+		}
+		if se.instance != "" {
+			p.Instance = se.instance
+		}
+		for k, v := range se.extensions {
+			p.Extensions[k] = v
+		}
+	}
+	if len(p.Extensions) == 0 {
+		p.Extensions = nil
+	}
+	return p
+}
+
+// formats lists the supported response formats, in order of preference when
+// the client accepts several equally.
+var formats = []struct {
+	mediaType   string
+	contentType string
+}{
+	{"text/plain", ""},
+	{ContentTypeProblemJSON, ContentTypeProblemJSON},
+	{"application/json", ContentTypeProblemJSON},
+	{ContentTypeProblemXML, ContentTypeProblemXML},
+	{"application/xml", ContentTypeProblemXML},
+	{"text/xml", ContentTypeProblemXML},
+}
+
+// negotiate returns the problem content type best matching the Accept header,
+// or an empty string if plain text should be served. Problem details are only
+// served if the client names a problem format more specifically than */*, and
+// prefers it to text/html, so that browsers, which accept XML with a lower
+// quality than HTML, receive plain text.
+func negotiate(accept string) string {
+	html := acceptQuality(accept, "text/html")
+	bestQ, best := 0.0, ""
+	for _, f := range formats {
+		q, specificity := acceptMatch(accept, f.mediaType)
+		if f.contentType != "" && (specificity == 0 || q <= html) {
+			continue
+		}
+		if q > bestQ {
+			bestQ, best = q, f.contentType
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality value given to mediaType by the Accept
+// header. An empty Accept header accepts everything.
+func acceptQuality(accept, mediaType string) float64 {
+	q, _ := acceptMatch(accept, mediaType)
+	return q
+}
+
+// acceptMatch returns the quality value given to mediaType by the Accept
+// header, and the specificity of the matching media range: 2 for an exact
+// match, 1 for a type/* range, and 0 for */*, or an empty Accept header.
+func acceptMatch(accept, mediaType string) (float64, int) {
+	if strings.TrimSpace(accept) == "" {
+		return 1, 0
+	}
+	best, specificity := 0.0, -1
+	for _, spec := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(spec))
+		if err != nil {
+			continue
+		}
+		var s int
+		switch {
+		case mt == mediaType:
+			s = 2
+		case strings.HasSuffix(mt, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mt, "*")):
+			s = 1
+		case mt == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		best, specificity = q, s
+	}
+	return best, specificity
+}
+
+// HandleRequestError serves an error response if e is non-nil, choosing the
+// response format according to the request's Accept header. Clients which
+// accept application/problem+json (or application/json) receive RFC 9457
+// problem details as JSON, and clients which accept application/problem+xml
+// (or application/xml) receive them as XML, provided that they name the
+// format more specifically than */*, and prefer it to text/html. Otherwise,
+// the plain text response of HandleError is served, as it is to browsers. If
+// e is nil, this function is a no-op.
+//
+// As with HandleError, errors are only logged and reported if a response has
+// already been written to w.
+//
+// In Development mode, the HTML debug page is served for 5xx errors to
+// clients which prefer text/html. See WantsDebugPage.
+//
+// HandleRequestError uses the Config carried by r's context, if any, or else
+// DefaultConfig. See WithConfig. If r is nil, plain text is served.
+func HandleRequestError(w http.ResponseWriter, r *http.Request, e error) error {
+	return GetConfig(r).HandleRequestError(w, r, e)
+}
+
+// HandleRequestError works as the package-level HandleRequestError, but uses
+// the settings of c.
+func (c *Config) HandleRequestError(w http.ResponseWriter, r *http.Request, e error) error {
+	if e == nil {
+		return nil
+	}
+	if done, _ := donewriter.WriterIsDone(w); done {
+		return c.responseStarted(r, e)
+	}
+	if c.wantsDebugPage(r, e) {
+		return c.writeDebugPage(w, r, e)
+	}
+	var accept string
+	if r != nil {
+		accept = r.Header.Get("Accept")
+	}
+	contentType := negotiate(accept)
+	if contentType == "" {
+		return c.handleError(w, r, e)
+	}
+	return c.writeProblem(w, r, e, contentType)
+}
+
+// writeProblem serves e as problem details of the given content type.
+func (c *Config) writeProblem(w http.ResponseWriter, r *http.Request, e error, contentType string) error {
+	problem := c.ToProblem(e)
+	msg, id := c.clientMessage(w, r, e, problem.StatusCode())
+	if id != "" {
+		problem.Detail = msg
+		if problem.Extensions == nil {
+			problem.Extensions = make(map[string]interface{})
+		}
+		problem.Extensions["correlation_id"] = id
+	}
+	var body []byte
+	var err error
+	if contentType == ContentTypeProblemJSON {
+		body, err = json.Marshal(problem)
+	} else {
+		body, err = xml.Marshal(problem)
+		body = append([]byte(xml.Header), body...)
+	}
+	if err != nil {
+		return c.writeText(w, r, e)
+	}
+	setHeaders(w, e)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(problem.StatusCode())
+	_, err = w.Write(body)
+	c.report(r, e, problem.StatusCode(), id)
+	return err
+}
@@ -0,0 +1,292 @@
+package httperr
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestProblemMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		problem  *Problem
+		expected string
+	}{
+		{
+			name:     "status only",
+			problem:  &Problem{Status: http.StatusNotFound},
+			expected: `{"status":404}`,
+		},
+		{
+			name:     "default status",
+			problem:  &Problem{Title: "Oops"},
+			expected: `{"status":500,"title":"Oops"}`,
+		},
+		{
+			name: "all members",
+			problem: &Problem{
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "You do not have enough credit.",
+				Status:   http.StatusForbidden,
+				Detail:   "Your current balance is 30, but that costs 50.",
+				Instance: "/account/12345/msgs/abc",
+				Extensions: map[string]interface{}{
+					"balance": 30,
+					"status":  "ignored",
+				},
+			},
+			expected: `{"balance":30,"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","status":403,"title":"You do not have enough credit.","type":"https://example.com/probs/out-of-credit"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := json.Marshal(test.problem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.expected, string(result)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestProblemMarshalXML(t *testing.T) {
+	problem := &Problem{
+		Type:       "https://example.com/probs/out-of-credit",
+		Title:      "You do not have enough credit.",
+		Status:     http.StatusForbidden,
+		Extensions: map[string]interface{}{"balance": 30},
+	}
+	result, err := xml.Marshal(problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<problem xmlns="urn:ietf:rfc:7807"><balance>30</balance><status>403</status><title>You do not have enough credit.</title><type>https://example.com/probs/out-of-credit</type></problem>`
+	if d := diff.Text(expected, string(result)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestToProblem(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected *Problem
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "standard error",
+			err:  errors.New("foo"),
+			expected: &Problem{
+				Title:  "Internal Server Error",
+				Status: http.StatusInternalServerError,
+				Detail: "foo",
+			},
+		},
+		{
+			name: "status error",
+			err:  New(http.StatusNotFound, "no such user"),
+			expected: &Problem{
+				Title:  "Not Found",
+				Status: http.StatusNotFound,
+				Detail: "no such user",
+			},
+		},
+		{
+			name: "with options",
+			err: fmt.Errorf("outer: %w", Wrap(http.StatusConflict,
+				New(http.StatusBadRequest, "inner",
+					WithType("https://example.com/inner"),
+					WithInstance("/inner"),
+					WithExtension("foo", "inner"),
+					WithExtension("bar", "inner"),
+				),
+				WithType("https://example.com/outer"),
+				WithExtension("foo", "outer"),
+			)),
+			expected: &Problem{
+				Type:     "https://example.com/outer",
+				Title:    "Conflict",
+				Status:   http.StatusConflict,
+				Detail:   "outer: inner",
+				Instance: "/inner",
+				Extensions: map[string]interface{}{
+					"foo": "outer",
+					"bar": "inner",
+				},
+			},
+		},
+		{
+			name: "wrapped problem",
+			err:  fmt.Errorf("oops: %w", &Problem{Title: "Bad", Status: http.StatusBadRequest}),
+			expected: &Problem{
+				Title:  "Bad",
+				Status: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "problem with outer status",
+			err: Wrap(http.StatusBadRequest,
+				&Problem{Title: "Not Found", Status: http.StatusNotFound, Detail: "no such user"},
+				WithCode("user_missing"),
+				WithInstance("/users/1"),
+			),
+			expected: &Problem{
+				Title:    "Bad Request",
+				Status:   http.StatusBadRequest,
+				Detail:   "no such user",
+				Instance: "/users/1",
+				Extensions: map[string]interface{}{
+					"code": "user_missing",
+				},
+			},
+		},
+		{
+			name: "problem with public message",
+			err: Wrap(http.StatusBadGateway,
+				&Problem{Title: "Bad Gateway", Status: http.StatusBadGateway, Detail: "upstream detail"},
+				WithPublicMessage("The upstream service failed"),
+			),
+			expected: &Problem{
+				Title:  "Bad Gateway",
+				Status: http.StatusBadGateway,
+				Detail: "The upstream service failed",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := ToProblem(test.err)
+			if d := diff.Interface(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{accept: "", expected: ""},
+		{accept: "*/*", expected: ""},
+		{accept: "text/html", expected: ""},
+		{accept: "application/json", expected: ContentTypeProblemJSON},
+		{accept: "application/problem+json", expected: ContentTypeProblemJSON},
+		{accept: "application/problem+xml", expected: ContentTypeProblemXML},
+		{accept: "application/*", expected: ContentTypeProblemJSON},
+		{accept: "text/plain;q=0.5, application/problem+xml", expected: ContentTypeProblemXML},
+		{accept: "application/problem+json;q=0.5, application/problem+xml;q=0.8", expected: ContentTypeProblemXML},
+		{accept: "application/*;q=0.1, text/*;q=0.9", expected: ""},
+		{accept: "*/*;q=0.1, application/json", expected: ContentTypeProblemJSON},
+		{accept: "application/json;q=0, */*", expected: ""},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: ""},
+		{accept: "text/html;q=0.5, application/json", expected: ContentTypeProblemJSON},
+		{accept: "text/html, application/json", expected: ""},
+		{accept: "text/*, application/xml", expected: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			if result := negotiate(test.accept); result != test.expected {
+				t.Errorf("Unexpected result: %q", result)
+			}
+		})
+	}
+}
+
+func TestHandleRequestError(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		e           error
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:   "no error",
+			status: http.StatusOK,
+		},
+		{
+			name:   "plain text",
+			accept: "text/html",
+			e:      New(http.StatusNotFound, "not found"),
+			status: http.StatusNotFound,
+			body:   "Error 404: not found",
+		},
+		{
+			name:   "browser",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			e:      New(http.StatusNotFound, "not found"),
+			status: http.StatusNotFound,
+			body:   "Error 404: not found",
+		},
+		{
+			name:        "problem json",
+			accept:      "application/json",
+			e:           New(http.StatusNotFound, "no such user", WithType("https://example.com/no-user"), WithExtension("user", "bob")),
+			status:      http.StatusNotFound,
+			contentType: ContentTypeProblemJSON,
+			body:        `{"detail":"no such user","status":404,"title":"Not Found","type":"https://example.com/no-user","user":"bob"}`,
+		},
+		{
+			name:        "problem xml",
+			accept:      "application/problem+xml",
+			e:           New(http.StatusNotFound, "no such user"),
+			status:      http.StatusNotFound,
+			contentType: ContentTypeProblemXML,
+			body:        xml.Header + `<problem xmlns="urn:ietf:rfc:7807"><detail>no such user</detail><status>404</status><title>Not Found</title></problem>`,
+		},
+		{
+			name:        "wrapped problem json",
+			accept:      "application/json",
+			e:           Wrap(http.StatusBadRequest, &Problem{Title: "Not Found", Status: http.StatusNotFound, Detail: "no such user"}),
+			status:      http.StatusBadRequest,
+			contentType: ContentTypeProblemJSON,
+			body:        `{"detail":"no such user","status":400,"title":"Bad Request"}`,
+		},
+		{
+			name:   "wrapped problem plain text",
+			accept: "text/plain",
+			e:      Wrap(http.StatusBadRequest, &Problem{Title: "Not Found", Status: http.StatusNotFound, Detail: "no such user"}),
+			status: http.StatusBadRequest,
+			body:   "Error 400: no such user",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", test.accept)
+			if err := HandleRequestError(w, r, test.e); err != nil {
+				t.Fatal(err)
+			}
+			res := w.Result()
+			defer res.Body.Close()
+			if test.status != res.StatusCode {
+				t.Errorf("Unexpected status code: %d", res.StatusCode)
+			}
+			if ct := res.Header.Get("Content-Type"); test.contentType != ct {
+				t.Errorf("Unexpected Content-Type: %s", ct)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
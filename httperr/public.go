@@ -0,0 +1,108 @@
+package httperr
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Production, when true, hides the internal messages of 5xx errors from
+// clients. Such responses contain only the generic status text and a
+// correlation ID, unless a public message was attached to the error with
+// WithPublicMessage. The full internal error is logged to ErrorLog, along with
+// the correlation ID. It is the default for Config.Production.
+var Production = false
+
+// ErrorLog is the logger to which internal errors are logged in production
+// mode. If nil, errors are logged via the log package's standard logger. It
+// is the default for Config.ErrorLog.
+var ErrorLog *log.Logger
+
+// CorrelationIDHeader is the request header from which the correlation ID of
+// a hidden error is taken, if present. The correlation ID is also returned to
+// the client in this response header.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// NewCorrelationID returns a new random correlation ID, used for hidden errors
+// when the request does not carry one. It may be replaced to integrate with
+// existing request ID schemes. It is the default for Config.NewCorrelationID.
+var NewCorrelationID = randomCorrelationID
+
+// WithPublicMessage attaches a client-safe message to the error. The public
+// message is served to clients in place of the error's internal message,
+// which may include details such as wrapped database errors or file paths.
+func WithPublicMessage(msg string) Option {
+	return func(e *statusError) {
+		e.public = msg
+	}
+}
+
+// PublicMessage returns the client-safe message of the outermost error in
+// err's chain with a public message attached by WithPublicMessage. If there
+// is no such error, err.Error() is returned. For aggregate errors, the
+// messages of the members are returned, separated by newlines. If err is nil,
+// an empty string is returned.
+func PublicMessage(err error) string {
+	return DefaultConfig().PublicMessage(err)
+}
+
+// PublicMessage works as the package-level PublicMessage, but hides the
+// messages of aggregate members according to c.Production.
+func (c *Config) PublicMessage(err error) string {
+	if msg, ok := c.publicMessage(err); ok {
+		return msg
+	}
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (c *Config) publicMessage(err error) (string, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if se, ok := e.(*statusError); ok && se.public != "" {
+			return se.public, true
+		}
+		if members, ok := e.(interface{ Unwrap() []error }); ok {
+			return c.multiPublicMessage(members.Unwrap())
+		}
+	}
+	return "", false
+}
+
+// ClientMessage returns the client-safe message for err, served in response
+// to r, as it would be served by HandleRequestError with the request's
+// Config. In production mode, the messages of 5xx errors with no public
+// message are replaced by the generic status text, and the full error is
+// logged along with a correlation ID, which is set in w's response header and
+// returned as id. It is intended for renderers which serve errors in their
+// own format, such as error templates, which should then report the error
+// with ReportErrorID.
+func ClientMessage(w http.ResponseWriter, r *http.Request, err error) (msg, id string) {
+	c := GetConfig(r)
+	return c.clientMessage(w, r, err, c.StatusCode(err))
+}
+
+// clientMessage returns the message to be served to the client for err, which
+// has the given status. If the message is hidden in production mode, the
+// full error is logged, the correlation ID is set in the response header, and
+// returned as id. r may be nil.
+func (c *Config) clientMessage(w http.ResponseWriter, r *http.Request, err error, status int) (msg, id string) {
+	if msg, ok := c.publicMessage(err); ok {
+		return msg, ""
+	}
+	var p *Problem
+	if !c.Production || status < http.StatusInternalServerError || errors.As(err, &p) {
+		return err.Error(), ""
+	}
+	if r != nil {
+		id = r.Header.Get(CorrelationIDHeader)
+	}
+	if id == "" {
+		id = c.newCorrelationID()
+	}
+	w.Header().Set(CorrelationIDHeader, id)
+	c.logf("[%s] Error %d: %+v", id, status, err)
+	return http.StatusText(status), id
+}
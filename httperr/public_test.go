@@ -0,0 +1,166 @@
+package httperr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestPublicMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:     "no public message",
+			err:      Wrapf(http.StatusInternalServerError, errors.New("dial tcp 10.0.0.1:5432: connection refused"), "loading user"),
+			expected: "loading user: dial tcp 10.0.0.1:5432: connection refused",
+		},
+		{
+			name:     "public message",
+			err:      Wrap(http.StatusInternalServerError, errors.New("dial tcp 10.0.0.1:5432: connection refused"), WithPublicMessage("The database is unavailable")),
+			expected: "The database is unavailable",
+		},
+		{
+			name: "outermost wins",
+			err: fmt.Errorf("outer: %w", Wrap(http.StatusBadRequest,
+				New(http.StatusNotFound, "inner", WithPublicMessage("inner message")),
+				WithPublicMessage("outer message"),
+			)),
+			expected: "outer message",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := PublicMessage(test.err); result != test.expected {
+				t.Errorf("Unexpected result: %s", result)
+			}
+		})
+	}
+}
+
+func TestProductionMode(t *testing.T) {
+	defer func(production bool, errorLog *log.Logger, newID func() string) {
+		Production, ErrorLog, NewCorrelationID = production, errorLog, newID
+	}(Production, ErrorLog, NewCorrelationID)
+	Production = true
+	NewCorrelationID = func() string { return "abc123" }
+
+	tests := []struct {
+		name          string
+		e             error
+		accept        string
+		requestID     string
+		status        int
+		correlationID string
+		body          string
+		log           string
+	}{
+		{
+			name:   "4xx shown",
+			e:      New(http.StatusNotFound, "no such user"),
+			status: http.StatusNotFound,
+			body:   "Error 404: no such user",
+		},
+		{
+			name:          "5xx hidden",
+			e:             Wrapf(http.StatusInternalServerError, errors.New("open /etc/secret: permission denied"), "loading config"),
+			status:        http.StatusInternalServerError,
+			correlationID: "abc123",
+			body:          "Error 500: Internal Server Error (correlation ID: abc123)",
+			log:           "[abc123] Error 500: loading config: open /etc/secret: permission denied\n",
+		},
+		{
+			name:          "request correlation ID",
+			e:             errors.New("boom"),
+			requestID:     "req-1",
+			status:        http.StatusInternalServerError,
+			correlationID: "req-1",
+			body:          "Error 500: Internal Server Error (correlation ID: req-1)",
+			log:           "[req-1] Error 500: boom\n",
+		},
+		{
+			name:   "5xx public message",
+			e:      Wrap(http.StatusServiceUnavailable, errors.New("dial tcp: refused"), WithPublicMessage("Try again later")),
+			status: http.StatusServiceUnavailable,
+			body:   "Error 503: Try again later",
+		},
+		{
+			name:          "problem json",
+			e:             errors.New("boom"),
+			accept:        "application/json",
+			status:        http.StatusInternalServerError,
+			correlationID: "abc123",
+			body:          `{"correlation_id":"abc123","detail":"Internal Server Error","status":500,"title":"Internal Server Error"}`,
+			log:           "[abc123] Error 500: boom\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logBuf := &bytes.Buffer{}
+			ErrorLog = log.New(logBuf, "", 0)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", test.accept)
+			if test.requestID != "" {
+				r.Header.Set(CorrelationIDHeader, test.requestID)
+			}
+			if err := HandleRequestError(w, r, test.e); err != nil {
+				t.Fatal(err)
+			}
+			res := w.Result()
+			defer res.Body.Close()
+			if test.status != res.StatusCode {
+				t.Errorf("Unexpected status code: %d", res.StatusCode)
+			}
+			if id := res.Header.Get(CorrelationIDHeader); id != test.correlationID {
+				t.Errorf("Unexpected correlation ID: %s", id)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+			if d := diff.Text(test.log, logBuf.String()); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestClientMessage(t *testing.T) {
+	reporter := &MemoryReporter{}
+	c := &Config{
+		Production:       true,
+		ErrorLog:         log.New(ioutil.Discard, "", 0),
+		NewCorrelationID: func() string { return "abc123" },
+		Reporter:         reporter,
+	}
+	w := httptest.NewRecorder()
+	r := WithConfig(httptest.NewRequest(http.MethodGet, "/", nil), c)
+	err := errors.New("db password rejected")
+	msg, id := ClientMessage(w, r, err)
+	if msg != "Internal Server Error" || id != "abc123" {
+		t.Errorf("Unexpected result: %q, %q", msg, id)
+	}
+	if h := w.Header().Get(CorrelationIDHeader); h != id {
+		t.Errorf("Unexpected %s header: %s", CorrelationIDHeader, h)
+	}
+	ReportErrorID(r, err, id)
+	if reports := reporter.Reports(); len(reports) != 1 || reports[0].CorrelationID != id {
+		t.Errorf("Unexpected reports: %v", reports)
+	}
+}
@@ -0,0 +1,89 @@
+package httperr
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/flimzy/juniper/donewriter"
+)
+
+// PanicError is an error produced from a panic recovered by Recover.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+var _ error = &PanicError{}
+var _ statusCoder = &PanicError{}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// StatusCode returns 500 (internal server error).
+func (e *PanicError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// Is returns true if target is ErrInternalServerError.
+func (e *PanicError) Is(target error) bool {
+	return isStatus(e.StatusCode(), target)
+}
+
+// Unwrap returns the panic value, if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// PanicReporter is called with each panic recovered by Recover.
+type PanicReporter func(r *http.Request, err *PanicError)
+
+// LogPanic is a PanicReporter which logs the panic and its stack trace to
+// the ErrorLog of the request's Config.
+func LogPanic(r *http.Request, err *PanicError) {
+	GetConfig(r).logf("%s %s: %s\n%s", r.Method, r.URL, err, err.Stack)
+}
+
+// Recover returns a middleware which recovers from panics in subsequent
+// handlers. The panic is converted to a *PanicError, passed to reporter, and
+// served with Respond, unless a response has already been started. If reporter
+// is nil, LogPanic is used.
+//
+// Panics with the value http.ErrAbortHandler are not recovered, so that the
+// handler may still be aborted.
+//
+// To render panics with the error template of the view middleware, Recover
+// must come after the view middleware in the middleware stack.
+func Recover(reporter PanicReporter) func(http.Handler) http.Handler {
+	if reporter == nil {
+		reporter = LogPanic
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			w, ok := rw.(donewriter.DoneWriter)
+			if !ok {
+				w = donewriter.New(rw)
+			}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				err := &PanicError{Value: v, Stack: debug.Stack()}
+				reporter(r, err)
+				if w.Done() {
+					return
+				}
+				Respond(w, r, err)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package httperr
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		status   int
+		body     string
+		reported string
+	}{
+		{
+			name: "no panic",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			}),
+			status: http.StatusOK,
+			body:   "ok",
+		},
+		{
+			name: "panic",
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				panic("oh no")
+			}),
+			status:   http.StatusInternalServerError,
+			body:     "Error 500: panic: oh no",
+			reported: "panic: oh no",
+		},
+		{
+			name: "panic with error",
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				panic(errors.New("oh no"))
+			}),
+			status:   http.StatusInternalServerError,
+			body:     "Error 500: panic: oh no",
+			reported: "panic: oh no",
+		},
+		{
+			name: "response started",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("partial"))
+				panic("oh no")
+			}),
+			status:   http.StatusAccepted,
+			body:     "partial",
+			reported: "panic: oh no",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var reported *PanicError
+			reporter := func(_ *http.Request, err *PanicError) {
+				reported = err
+			}
+			w := httptest.NewRecorder()
+			Recover(reporter)(test.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != test.status {
+				t.Errorf("Unexpected status code: %d", res.StatusCode)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+			var result string
+			if reported != nil {
+				result = reported.Error()
+				if !strings.Contains(string(reported.Stack), "TestRecover") {
+					t.Errorf("Stack trace does not contain the panicking function:\n%s", reported.Stack)
+				}
+			}
+			if result != test.reported {
+				t.Errorf("Unexpected reported panic: %s", result)
+			}
+		})
+	}
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	handler := Recover(func(_ *http.Request, _ *PanicError) {
+		t.Error("Reporter should not be called")
+	})(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("Unexpected panic value: %v", v)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRecoverResponder(t *testing.T) {
+	var responded error
+	req := WithResponder(httptest.NewRequest(http.MethodGet, "/", nil), func(_ http.ResponseWriter, _ *http.Request, err error) {
+		responded = err
+	})
+	handler := Recover(func(_ *http.Request, _ *PanicError) {})(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		panic("oh no")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if StatusCode(responded) != http.StatusInternalServerError {
+		t.Errorf("Unexpected error: %v", responded)
+	}
+}
@@ -0,0 +1,249 @@
+package httperr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Report describes an error served in response to a request.
+type Report struct {
+	// Time is the time the error was reported.
+	Time time.Time `json:"time"`
+	// Err is the reported error.
+	Err error `json:"-"`
+	// Message is the full, internal error message.
+	Message string `json:"message"`
+	// Status is the HTTP status code of the error.
+	Status int `json:"status"`
+	// Code is the application error code, if any.
+	Code string `json:"code,omitempty"`
+	// CorrelationID is the correlation ID served to the client, if any.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// Method, URL, RemoteAddr, UserAgent and Referer describe the request.
+	Method     string `json:"method,omitempty"`
+	URL        string `json:"url,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	Referer    string `json:"referer,omitempty"`
+	// Stack is the formatted stack trace of the error, if one was captured by
+	// github.com/pkg/errors, or by Recover.
+	Stack string `json:"stack,omitempty"`
+}
+
+// Reporter receives reports of errors served by this package, for instance
+// to forward them to an error tracking service. Report must be safe for
+// concurrent use.
+type Reporter interface {
+	Report(*Report)
+}
+
+// DefaultReporter, if set, receives a report of each error served by
+// HandleError, HandleRequestError, and handlers adapted by HandlerFunc and
+// MiddlewareFunc. It is the default for Config.Reporter.
+var DefaultReporter Reporter
+
+// stackTracer is implemented by errors created by github.com/pkg/errors.
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// ReportError sends a report of err, served in response to r, to the Reporter
+// of the request's Config, if set. It is called by the functions of this
+// package which serve errors, and may be called by other code which does so,
+// such as error template renderers. r may be nil. If err is nil, this
+// function is a no-op.
+func ReportError(r *http.Request, err error) {
+	var id string
+	if r != nil {
+		id = r.Header.Get(CorrelationIDHeader)
+	}
+	ReportErrorID(r, err, id)
+}
+
+// ReportErrorID works as ReportError, but reports the correlation ID id, such
+// as one returned by ClientMessage.
+func ReportErrorID(r *http.Request, err error, id string) {
+	c := GetConfig(r)
+	c.report(r, err, c.StatusCode(err), id)
+}
+
+func (c *Config) report(r *http.Request, err error, status int, id string) {
+	reporter := c.Reporter
+	if reporter == nil || err == nil {
+		return
+	}
+	rep := &Report{
+		Time:          time.Now(),
+		Err:           err,
+		Message:       err.Error(),
+		Status:        status,
+		Code:          Code(err),
+		CorrelationID: id,
+	}
+	if r != nil {
+		rep.Method = r.Method
+		rep.URL = r.URL.String()
+		rep.RemoteAddr = r.RemoteAddr
+		rep.UserAgent = r.UserAgent()
+		rep.Referer = r.Referer()
+	}
+	// The innermost stack trace is the most complete.
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch t := e.(type) {
+		case *PanicError:
+			rep.Stack = string(t.Stack)
+		case stackTracer:
+			rep.Stack = fmt.Sprintf("%+v", t.StackTrace())
+		}
+	}
+	reporter.Report(rep)
+}
+
+// SamplingConfig configures a sampling Reporter.
+type SamplingConfig struct {
+	// Rates maps status classes, such as 4 for 4xx errors, or 5 for 5xx
+	// errors, to the fraction of errors of that class which are reported,
+	// between 0 and 1. Errors of classes which are not listed are always
+	// reported.
+	Rates map[int]float64
+	// DedupWindow, if set, is the period after an error is reported during
+	// which identical errors, with the same status, code and message, are not
+	// reported.
+	DedupWindow time.Duration
+}
+
+type samplingReporter struct {
+	Reporter
+	rates  map[int]float64
+	window time.Duration
+	now    func() time.Time
+	random func() float64
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewSamplingReporter returns a Reporter which forwards a sample of reports to
+// r, as configured by c.
+func NewSamplingReporter(r Reporter, c SamplingConfig) Reporter {
+	return &samplingReporter{
+		Reporter: r,
+		rates:    c.Rates,
+		window:   c.DedupWindow,
+		now:      time.Now,
+		random:   rand.Float64,
+		seen:     make(map[string]time.Time),
+	}
+}
+
+func (s *samplingReporter) Report(rep *Report) {
+	if rate, ok := s.rates[rep.Status/100]; ok && s.random() >= rate {
+		return
+	}
+	if s.window > 0 && s.duplicate(rep) {
+		return
+	}
+	s.Reporter.Report(rep)
+}
+
+// duplicate returns true if an identical error was reported within the dedup
+// window, and otherwise records rep.
+func (s *samplingReporter) duplicate(rep *Report) bool {
+	key := fmt.Sprintf("%d\x00%s\x00%s", rep.Status, rep.Code, rep.Message)
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.seen[key]; ok && now.Sub(last) < s.window {
+		return true
+	}
+	for k, last := range s.seen {
+		if now.Sub(last) >= s.window {
+			delete(s.seen, k)
+		}
+	}
+	s.seen[key] = now
+	return false
+}
+
+// MemoryReporter is a Reporter which retains reports in memory, for use in
+// tests.
+type MemoryReporter struct {
+	mu      sync.Mutex
+	reports []*Report
+}
+
+var _ Reporter = &MemoryReporter{}
+
+// Report retains rep.
+func (m *MemoryReporter) Report(rep *Report) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reports = append(m.reports, rep)
+}
+
+// Reports returns the retained reports, in the order they were reported.
+func (m *MemoryReporter) Reports() []*Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Report(nil), m.reports...)
+}
+
+// Reset discards the retained reports.
+func (m *MemoryReporter) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reports = nil
+}
+
+// JSONReporter is a Reporter which writes each report as a line of JSON.
+type JSONReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ Reporter = &JSONReporter{}
+
+// NewJSONReporter returns a Reporter which writes reports to w, one JSON
+// object per line.
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{w: w}
+}
+
+// OpenJSONReporter returns a JSONReporter which appends reports to the file
+// at path, creating it if necessary. The file should be closed with Close.
+func OpenJSONReporter(path string) (*JSONReporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open report file")
+	}
+	return NewJSONReporter(f), nil
+}
+
+// Report writes rep. Failures are logged to ErrorLog.
+func (j *JSONReporter) Report(rep *Report) {
+	line, err := json.Marshal(rep)
+	if err == nil {
+		j.mu.Lock()
+		_, err = j.w.Write(append(line, '\n'))
+		j.mu.Unlock()
+	}
+	if err != nil {
+		DefaultConfig().logf("Failed to write error report: %s", err)
+	}
+}
+
+// Close closes the underlying writer, if it is an io.Closer.
+func (j *JSONReporter) Close() error {
+	if c, ok := j.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package httperr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+
+	"github.com/flimzy/diff"
+)
+
+func TestReportError(t *testing.T) {
+	reporter := &MemoryReporter{}
+	defer func(r Reporter) {
+		DefaultReporter = r
+	}(DefaultReporter)
+	DefaultReporter = reporter
+
+	t.Run("HandleError", func(t *testing.T) {
+		reporter.Reset()
+		_ = HandleError(httptest.NewRecorder(), NewCode(testCodeEmailTaken))
+		reports := reporter.Reports()
+		if len(reports) != 1 {
+			t.Fatalf("Expected 1 report, got %d", len(reports))
+		}
+		rep := reports[0]
+		rep.Time, rep.Err, rep.Stack = time.Time{}, nil, ""
+		expected := &Report{
+			Message: "Email address already in use",
+			Status:  http.StatusConflict,
+			Code:    testCodeEmailTaken,
+		}
+		if d := diff.Interface(expected, rep); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("HandlerFunc", func(t *testing.T) {
+		reporter.Reset()
+		defer func(p bool) {
+			Production = p
+		}(Production)
+		Production = true
+		h := HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+			return pkgerrors.New("db down")
+		})
+		r := httptest.NewRequest(http.MethodPost, "/users?x=1", nil)
+		r.Header.Set("Accept", ContentTypeProblemJSON)
+		r.Header.Set("User-Agent", "test")
+		r.Header.Set(CorrelationIDHeader, "abc")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		reports := reporter.Reports()
+		if len(reports) != 1 {
+			t.Fatalf("Expected 1 report, got %d", len(reports))
+		}
+		rep := reports[0]
+		if !strings.Contains(rep.Stack, "TestReportError") {
+			t.Errorf("Unexpected stack: %s", rep.Stack)
+		}
+		rep.Time, rep.Err, rep.Stack = time.Time{}, nil, ""
+		expected := &Report{
+			Message:       "db down",
+			Status:        http.StatusInternalServerError,
+			CorrelationID: "abc",
+			Method:        http.MethodPost,
+			URL:           "/users?x=1",
+			RemoteAddr:    "192.0.2.1:1234",
+			UserAgent:     "test",
+		}
+		if d := diff.Interface(expected, rep); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("nil", func(t *testing.T) {
+		reporter.Reset()
+		ReportError(nil, nil)
+		if n := len(reporter.Reports()); n != 0 {
+			t.Errorf("Expected no reports, got %d", n)
+		}
+	})
+}
+
+func TestSamplingReporter(t *testing.T) {
+	reporter := &MemoryReporter{}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSamplingReporter(reporter, SamplingConfig{
+		Rates:       map[int]float64{4: 0.5},
+		DedupWindow: time.Minute,
+	}).(*samplingReporter)
+	s.now = func() time.Time { return now }
+	random := 0.0
+	s.random = func() float64 { return random }
+
+	s.Report(&Report{Status: http.StatusNotFound, Message: "a"})
+	random = 0.7
+	s.Report(&Report{Status: http.StatusNotFound, Message: "b"}) // not sampled
+	s.Report(&Report{Status: http.StatusInternalServerError, Message: "c"})
+	now = now.Add(30 * time.Second)
+	s.Report(&Report{Status: http.StatusInternalServerError, Message: "c"}) // duplicate
+	s.Report(&Report{Status: http.StatusInternalServerError, Code: "x", Message: "c"})
+	now = now.Add(31 * time.Second)
+	s.Report(&Report{Status: http.StatusInternalServerError, Message: "c"}) // window expired
+
+	var messages []string
+	for _, rep := range reporter.Reports() {
+		messages = append(messages, rep.Code+rep.Message)
+	}
+	if d := diff.Interface([]string{"a", "c", "xc", "c"}, messages); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	reporter := NewJSONReporter(buf)
+	reporter.Report(&Report{
+		Time:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Err:     errors.New("foo"),
+		Message: "foo",
+		Status:  http.StatusNotFound,
+	})
+	reporter.Report(&Report{
+		Time:    time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC),
+		Message: "bar",
+		Status:  http.StatusInternalServerError,
+		Method:  http.MethodGet,
+		URL:     "/",
+	})
+	expected := `{"time":"2020-01-01T00:00:00Z","message":"foo","status":404}
+{"time":"2020-01-01T00:00:01Z","message":"bar","status":500,"method":"GET","url":"/"}
+`
+	if d := diff.Text(expected, buf.String()); d != nil {
+		t.Error(d)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("Invalid JSON line: %s", line)
+		}
+	}
+	if err := reporter.Close(); err != nil {
+		t.Error(err)
+	}
+}
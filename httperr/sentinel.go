@@ -0,0 +1,66 @@
+package httperr
+
+import "net/http"
+
+// sentinelError is a status-embedded error, which is matched by errors.Is for
+// any error in a chain with the same status code.
+type sentinelError struct {
+	status int
+}
+
+var _ statusCoder = &sentinelError{}
+
+func newSentinel(status int) error {
+	return &sentinelError{status: status}
+}
+
+func (e *sentinelError) Error() string {
+	return http.StatusText(e.status)
+}
+
+func (e *sentinelError) StatusCode() int {
+	return e.status
+}
+
+// isStatus returns true if target is the sentinel error for status. It is
+// used to implement the Is method of this package's error types.
+func isStatus(status int, target error) bool {
+	t, ok := target.(*sentinelError)
+	return ok && t.status == status
+}
+
+// Sentinel errors for common status codes. They may be returned directly, and
+// are matched by errors.Is for any error created by this package with the same
+// status code, including ValidationError, PanicError, Problem and MultiError:
+//
+//  err := httperr.New(http.StatusNotFound, "no such user")
+//  errors.Is(err, httperr.ErrNotFound) // true
+//
+// As errors.Is considers each error in the chain in turn, a sentinel matches
+// if any error in the chain has its status code, regardless of precedence. It
+// therefore answers whether the status occurred, not whether it would be
+// served. Where an outer error overrides the status, the two differ:
+//
+//  err := httperr.Wrap(http.StatusBadRequest, httperr.New(http.StatusNotFound, "no such user"))
+//  errors.Is(err, httperr.ErrNotFound) // true
+//  httperr.StatusCode(err)             // 400
+//
+// To test the status which would be served, compare the result of StatusCode.
+var (
+	ErrBadRequest          = newSentinel(http.StatusBadRequest)
+	ErrUnauthorized        = newSentinel(http.StatusUnauthorized)
+	ErrForbidden           = newSentinel(http.StatusForbidden)
+	ErrNotFound            = newSentinel(http.StatusNotFound)
+	ErrMethodNotAllowed    = newSentinel(http.StatusMethodNotAllowed)
+	ErrNotAcceptable       = newSentinel(http.StatusNotAcceptable)
+	ErrConflict            = newSentinel(http.StatusConflict)
+	ErrGone                = newSentinel(http.StatusGone)
+	ErrRequestTooLarge     = newSentinel(http.StatusRequestEntityTooLarge)
+	ErrUnprocessable       = newSentinel(http.StatusUnprocessableEntity)
+	ErrTooManyRequests     = newSentinel(http.StatusTooManyRequests)
+	ErrInternalServerError = newSentinel(http.StatusInternalServerError)
+	ErrNotImplemented      = newSentinel(http.StatusNotImplemented)
+	ErrBadGateway          = newSentinel(http.StatusBadGateway)
+	ErrServiceUnavailable  = newSentinel(http.StatusServiceUnavailable)
+	ErrGatewayTimeout      = newSentinel(http.StatusGatewayTimeout)
+)
@@ -0,0 +1,97 @@
+package httperr
+
+import (
+	"net/http"
+	"strings"
+)
+
+// FieldError describes a single invalid field.
+type FieldError struct {
+	// Field is the path of the invalid field, such as "email" or
+	// "addresses[0].zip".
+	Field string `json:"field" xml:"field"`
+	// Code is a machine-readable code describing the problem, such as
+	// "required".
+	Code string `json:"code,omitempty" xml:"code,omitempty"`
+	// Message is a human-readable description of the problem.
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationError is an error which reports one or more invalid fields. The
+// zero value is ready to use:
+//
+//  var verr httperr.ValidationError
+//  if form.Email == "" {
+//      verr.Add("email", "required", "Email is required")
+//  }
+//  return verr.Err()
+//
+// HandleError and HandleRequestError serve validation errors as problem
+// details, with the field errors in the "errors" member. Under the view
+// middleware, the error is stored in the stash, so that the form may be
+// re-rendered with inline errors.
+type ValidationError struct {
+	// Status is the HTTP status code. If zero, 422 (unprocessable entity) is
+	// used.
+	Status int
+	// Fields lists the invalid fields, in the order they were added.
+	Fields []FieldError
+}
+
+var _ error = &ValidationError{}
+var _ statusCoder = &ValidationError{}
+
+// Add adds a field error, and returns e, to allow chaining.
+func (e *ValidationError) Add(field, code, message string) *ValidationError {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+	return e
+}
+
+// Err returns e as an error if it contains any field errors, or nil
+// otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// StatusCode returns the error's status code, 422 by default.
+func (e *ValidationError) StatusCode() int {
+	if e.Status == 0 {
+		return http.StatusUnprocessableEntity
+	}
+	return e.Status
+}
+
+// Is returns true if target is the sentinel error for e's status code.
+func (e *ValidationError) Is(target error) bool {
+	return isStatus(e.StatusCode(), target)
+}
+
+// ForField returns the errors for the named field. This is intended for use
+// by templates:
+//
+//  {{ range ._validation.ForField "email" }}<span class="error">{{ .Message }}</span>{{ end }}
+func (e *ValidationError) ForField(field string) []FieldError {
+	var errs []FieldError
+	for _, f := range e.Fields {
+		if f.Field == field {
+			errs = append(errs, f)
+		}
+	}
+	return errs
+}
+
+// HasField returns true if there are any errors for the named field.
+func (e *ValidationError) HasField(field string) bool {
+	return len(e.ForField(field)) > 0
+}
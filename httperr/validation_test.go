@@ -0,0 +1,105 @@
+package httperr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flimzy/diff"
+)
+
+func TestValidationError(t *testing.T) {
+	var verr ValidationError
+	if err := verr.Err(); err != nil {
+		t.Fatalf("Expected nil error, got %s", err)
+	}
+	verr.Add("email", "required", "Email is required").
+		Add("name", "too_long", "Name is too long").
+		Add("email", "invalid", "Email is invalid")
+	err := verr.Err()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if d := diff.Text("validation failed: email: Email is required; name: Name is too long; email: Email is invalid", err.Error()); d != nil {
+		t.Error(d)
+	}
+	if status := StatusCode(fmt.Errorf("saving: %w", err)); status != http.StatusUnprocessableEntity {
+		t.Errorf("Unexpected status: %d", status)
+	}
+	expected := []FieldError{
+		{Field: "email", Code: "required", Message: "Email is required"},
+		{Field: "email", Code: "invalid", Message: "Email is invalid"},
+	}
+	if d := diff.Interface(expected, verr.ForField("email")); d != nil {
+		t.Error(d)
+	}
+	if verr.HasField("age") {
+		t.Error("Unexpected errors for age")
+	}
+	verr.Status = http.StatusBadRequest
+	if status := StatusCode(err); status != http.StatusBadRequest {
+		t.Errorf("Unexpected status: %d", status)
+	}
+}
+
+func TestHandleValidationError(t *testing.T) {
+	verr := &ValidationError{}
+	verr.Add("email", "required", "Email is required")
+	tests := []struct {
+		name        string
+		handle      func(http.ResponseWriter, *http.Request, error) error
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name: "HandleError",
+			handle: func(w http.ResponseWriter, _ *http.Request, e error) error {
+				return HandleError(w, e)
+			},
+			contentType: ContentTypeProblemJSON,
+			body:        `{"detail":"validation failed: email: Email is required","errors":[{"field":"email","code":"required","message":"Email is required"}],"status":422,"title":"Unprocessable Entity"}`,
+		},
+		{
+			name:        "HandleRequestError, plain",
+			handle:      HandleRequestError,
+			accept:      "text/html",
+			contentType: ContentTypeProblemJSON,
+			body:        `{"detail":"validation failed: email: Email is required","errors":[{"field":"email","code":"required","message":"Email is required"}],"status":422,"title":"Unprocessable Entity"}`,
+		},
+		{
+			name:        "HandleRequestError, xml",
+			handle:      HandleRequestError,
+			accept:      ContentTypeProblemXML,
+			contentType: ContentTypeProblemXML,
+			body:        `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<problem xmlns="urn:ietf:rfc:7807"><detail>validation failed: email: Email is required</detail><errors><field>email</field><code>required</code><message>Email is required</message></errors><status>422</status><title>Unprocessable Entity</title></problem>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.Header.Set("Accept", test.accept)
+			if err := test.handle(w, r, verr); err != nil {
+				t.Fatal(err)
+			}
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusUnprocessableEntity {
+				t.Errorf("Unexpected status code: %d", res.StatusCode)
+			}
+			if ct := res.Header.Get("Content-Type"); ct != test.contentType {
+				t.Errorf("Unexpected Content-Type: %s", ct)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
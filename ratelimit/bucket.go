@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit is a token bucket rate limit. The bucket holds up to Burst tokens,
+// and is refilled at a rate of Burst tokens per Period. Each request takes
+// one token, and is rejected if the bucket is empty.
+type Limit struct {
+	// Burst is the capacity of the bucket, and the maximum number of requests
+	// permitted at once.
+	Burst int
+	// Period is the time taken to refill an empty bucket.
+	Period time.Duration
+}
+
+// PerSecond returns a Limit of n requests per second.
+func PerSecond(n int) Limit {
+	return Limit{Burst: n, Period: time.Second}
+}
+
+// PerMinute returns a Limit of n requests per minute.
+func PerMinute(n int) Limit {
+	return Limit{Burst: n, Period: time.Minute}
+}
+
+// interval returns the time taken to add one token to the bucket.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Burst)
+}
+
+// Result is the outcome of an attempt to take a token from a bucket.
+type Result struct {
+	// Allowed is true if a token was taken.
+	Allowed bool
+	// Limit is the capacity of the bucket.
+	Limit int
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available, if none
+	// remains.
+	RetryAfter time.Duration
+}
+
+// Bucket is the state of a token bucket. The zero value is a full bucket.
+// Bucket is not safe for concurrent use. It is exported so that Store
+// implementations may persist it.
+type Bucket struct {
+	// Taken is the number of tokens taken from the bucket, as of Updated.
+	Taken float64
+	// Updated is the time the bucket was last updated.
+	Updated time.Time
+}
+
+// refill updates the bucket to now, according to l.
+func (b *Bucket) refill(l Limit, now time.Time) {
+	if !b.Updated.IsZero() && now.After(b.Updated) {
+		b.Taken -= float64(now.Sub(b.Updated)) / float64(l.interval())
+		if b.Taken < 0 {
+			b.Taken = 0
+		}
+	}
+	if now.After(b.Updated) {
+		b.Updated = now
+	}
+}
+
+// Full returns true if the bucket will be full at now, according to l, so
+// that it may be discarded.
+func (b *Bucket) Full(l Limit, now time.Time) bool {
+	c := *b
+	c.refill(l, now)
+	return c.Taken == 0
+}
+
+// Take attempts to take a token from the bucket at now, according to l.
+func (b *Bucket) Take(l Limit, now time.Time) Result {
+	b.refill(l, now)
+	res := Result{Limit: l.Burst}
+	if b.Taken+1 <= float64(l.Burst) {
+		b.Taken++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = tokenTime(l, b.Taken+1-float64(l.Burst))
+	}
+	res.Remaining = int(math.Floor(float64(l.Burst) - b.Taken))
+	res.Reset = tokenTime(l, b.Taken)
+	return res
+}
+
+// tokenTime returns the time taken to refill n tokens, according to l.
+func tokenTime(l Limit, n float64) time.Duration {
+	return time.Duration(math.Round(n * float64(l.interval())))
+}
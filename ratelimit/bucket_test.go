@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flimzy/diff"
+)
+
+func TestBucketTake(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := Limit{Burst: 2, Period: 10 * time.Second}
+	b := &Bucket{}
+	tests := []struct {
+		name     string
+		at       time.Duration
+		expected Result
+	}{
+		{
+			name:     "first",
+			expected: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second},
+		},
+		{
+			name:     "second",
+			expected: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second},
+		},
+		{
+			name:     "empty",
+			at:       time.Second,
+			expected: Result{Limit: 2, Remaining: 0, Reset: 9 * time.Second, RetryAfter: 4 * time.Second},
+		},
+		{
+			name:     "refilled one",
+			at:       5 * time.Second,
+			expected: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second},
+		},
+		{
+			name:     "refilled all",
+			at:       time.Minute,
+			expected: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := b.Take(limit, start.Add(test.at))
+			if d := diff.Interface(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestBucketFull(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := PerSecond(10)
+	b := &Bucket{}
+	if !b.Full(limit, start) {
+		t.Error("New bucket should be full")
+	}
+	b.Take(limit, start)
+	if b.Full(limit, start.Add(50*time.Millisecond)) {
+		t.Error("Bucket should not be full yet")
+	}
+	if !b.Full(limit, start.Add(100*time.Millisecond)) {
+		t.Error("Bucket should be full again")
+	}
+}
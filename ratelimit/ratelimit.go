@@ -0,0 +1,128 @@
+// Package ratelimit provides a token bucket rate limiting middleware.
+//
+// Each limiter is configured independently, so that routes may have their
+// own limits:
+//
+//  store := ratelimit.NewMemoryStore(0)
+//  r.Use(ratelimit.New(ratelimit.Config{Limit: ratelimit.PerSecond(10), Store: store}))
+//  r.With(ratelimit.New(ratelimit.Config{
+//      Name:  "login",
+//      Limit: ratelimit.PerMinute(5),
+//      Store: store,
+//  })).Post("/login", login)
+//
+// Responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// headers. Rejected requests are served a 429 error with a Retry-After
+// header, by way of httperr.Respond, so that the error template of the view
+// middleware applies when the limiter comes after it in the middleware stack.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/flimzy/juniper/httperr"
+)
+
+// KeyFunc returns the key identifying the client of a request, whose requests
+// share a bucket. If the key is empty, the request is not limited.
+type KeyFunc func(r *http.Request) string
+
+// ByIP is a KeyFunc which identifies clients by their IP address, as given by
+// the request's RemoteAddr.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByHeader returns a KeyFunc which identifies clients by the value of the
+// named request header, such as an API key. Requests without the header are
+// not limited.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Config configures a rate limiter.
+type Config struct {
+	// Name distinguishes the buckets of this limiter from those of other
+	// limiters sharing the same Store.
+	Name string
+	// Limit is the rate limit applied to each client.
+	Limit Limit
+	// Key identifies the client of a request. If nil, ByIP is used.
+	Key KeyFunc
+	// Store holds the token buckets. If nil, a new MemoryStore is used.
+	Store Store
+}
+
+type limiter struct {
+	name  string
+	limit Limit
+	key   KeyFunc
+	store Store
+	now   func() time.Time
+}
+
+// New returns a new rate limiting middleware. New panics if c.Limit is not
+// positive.
+func New(c Config) func(http.Handler) http.Handler {
+	return newLimiter(c).middleware
+}
+
+func newLimiter(c Config) *limiter {
+	if c.Limit.Burst <= 0 || c.Limit.Period <= 0 {
+		panic("ratelimit: limit must be positive")
+	}
+	l := &limiter{
+		name:  c.Name,
+		limit: c.Limit,
+		key:   c.Key,
+		store: c.Store,
+		now:   time.Now,
+	}
+	if l.key == nil {
+		l.key = ByIP
+	}
+	if l.store == nil {
+		l.store = NewMemoryStore(0)
+	}
+	return l
+}
+
+func (l *limiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.key(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res, err := l.store.Take(r.Context(), l.name+"\x00"+key, l.limit, l.now())
+		if err != nil {
+			httperr.Respond(w, r, errors.Wrap(err, "rate limit store failed"))
+			return
+		}
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			httperr.Respond(w, r, httperr.TooManyRequests(res.RetryAfter))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// seconds formats d in whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
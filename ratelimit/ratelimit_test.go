@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flimzy/diff"
+
+	"github.com/flimzy/juniper/httperr"
+)
+
+type errStore struct{}
+
+func (errStore) Take(context.Context, string, Limit, time.Time) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func TestMiddleware(t *testing.T) {
+	type request struct {
+		remoteAddr string
+		apiKey     string
+		status     int
+		header     http.Header
+		body       string
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	tests := []struct {
+		name     string
+		conf     Config
+		requests []request
+	}{
+		{
+			name: "by IP",
+			conf: Config{Limit: PerMinute(2)},
+			requests: []request{
+				{
+					remoteAddr: "192.0.2.1:1000",
+					status:     http.StatusOK,
+					header:     http.Header{"Ratelimit-Limit": {"2"}, "Ratelimit-Remaining": {"1"}, "Ratelimit-Reset": {"30"}},
+					body:       "ok",
+				},
+				{
+					remoteAddr: "192.0.2.1:1001",
+					status:     http.StatusOK,
+					header:     http.Header{"Ratelimit-Limit": {"2"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"60"}},
+					body:       "ok",
+				},
+				{
+					remoteAddr: "192.0.2.1:1002",
+					status:     http.StatusTooManyRequests,
+					header:     http.Header{"Ratelimit-Limit": {"2"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"60"}, "Retry-After": {"30"}},
+					body:       "Error 429: Too Many Requests",
+				},
+				{
+					remoteAddr: "192.0.2.2:1000",
+					status:     http.StatusOK,
+					header:     http.Header{"Ratelimit-Limit": {"2"}, "Ratelimit-Remaining": {"1"}, "Ratelimit-Reset": {"30"}},
+					body:       "ok",
+				},
+			},
+		},
+		{
+			name: "by header",
+			conf: Config{Limit: PerSecond(1), Key: ByHeader("X-API-Key")},
+			requests: []request{
+				{
+					apiKey: "abc",
+					status: http.StatusOK,
+					header: http.Header{"Ratelimit-Limit": {"1"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"1"}},
+					body:   "ok",
+				},
+				{
+					apiKey: "abc",
+					status: http.StatusTooManyRequests,
+					header: http.Header{"Ratelimit-Limit": {"1"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"1"}, "Retry-After": {"1"}},
+					body:   "Error 429: Too Many Requests",
+				},
+				{
+					status: http.StatusOK,
+					header: http.Header{},
+					body:   "ok",
+				},
+			},
+		},
+		{
+			name: "store failure",
+			conf: Config{Limit: PerSecond(1), Store: errStore{}},
+			requests: []request{
+				{
+					status: http.StatusInternalServerError,
+					header: http.Header{},
+					body:   "Error 500: rate limit store failed: connection refused",
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLimiter(test.conf)
+			now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			l.now = func() time.Time { return now }
+			handler := l.middleware(ok)
+			for i, req := range test.requests {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				if req.remoteAddr != "" {
+					r.RemoteAddr = req.remoteAddr
+				}
+				if req.apiKey != "" {
+					r.Header.Set("X-API-Key", req.apiKey)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				res := w.Result()
+				if res.StatusCode != req.status {
+					t.Errorf("Request %d: unexpected status: %d", i, res.StatusCode)
+				}
+				res.Header.Del("Content-Type")
+				if d := diff.Interface(req.header, res.Header); d != nil {
+					t.Errorf("Request %d: %s", i, d)
+				}
+				body, _ := ioutil.ReadAll(res.Body)
+				if d := diff.Text(req.body, string(body)); d != nil {
+					t.Errorf("Request %d: %s", i, d)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareResponder(t *testing.T) {
+	handler := New(Config{Limit: Limit{Burst: 1, Period: time.Hour}})(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	var responded error
+	responder := func(w http.ResponseWriter, _ *http.Request, err error) {
+		responded = err
+		w.WriteHeader(httperr.StatusCode(err))
+	}
+	for i := 0; i < 2; i++ {
+		r := httperr.WithResponder(httptest.NewRequest(http.MethodGet, "/", nil), responder)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if !errors.Is(responded, httperr.ErrTooManyRequests) {
+		t.Errorf("Unexpected error: %v", responded)
+	}
+}
+
+func TestNewInvalidLimit(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+	New(Config{})
+}
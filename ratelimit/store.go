@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds the token buckets of a rate limiter. Implementations backed by
+// shared storage allow several servers to enforce a common limit. Take must
+// be safe for concurrent use, and should update the bucket atomically.
+type Store interface {
+	// Take attempts to take a token at now from the bucket identified by key,
+	// which has limit l. A missing bucket is full.
+	Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error)
+}
+
+// DefaultSweepInterval is the default interval at which a MemoryStore evicts
+// idle buckets.
+const DefaultSweepInterval = time.Minute
+
+type memoryBucket struct {
+	Bucket
+	limit Limit
+}
+
+// MemoryStore is a Store which holds buckets in memory. Buckets which have
+// refilled completely are evicted periodically, during calls to Take.
+type MemoryStore struct {
+	sweepInterval time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore returns a new MemoryStore, which evicts idle buckets every
+// sweepInterval. If sweepInterval is 0, DefaultSweepInterval is used.
+func NewMemoryStore(sweepInterval time.Duration) *MemoryStore {
+	if sweepInterval <= 0 {
+		sweepInterval = DefaultSweepInterval
+	}
+	return &MemoryStore{
+		sweepInterval: sweepInterval,
+		buckets:       make(map[string]*memoryBucket),
+	}
+}
+
+// Take attempts to take a token from the bucket identified by key.
+func (s *MemoryStore) Take(_ context.Context, key string, l Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= s.sweepInterval {
+		s.sweep(now)
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{}
+		s.buckets[key] = b
+	}
+	b.limit = l
+	return b.Take(l, now), nil
+}
+
+// Len returns the number of buckets held.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// sweep evicts full buckets. s.mu must be held.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.Full(b.limit, now) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
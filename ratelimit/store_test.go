@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore(time.Minute)
+	ctx := context.Background()
+	for _, key := range []string{"a", "b"} {
+		if res, _ := s.Take(ctx, key, PerMinute(1), start); !res.Allowed {
+			t.Errorf("Expected first request for %s to be allowed", key)
+		}
+	}
+	if res, _ := s.Take(ctx, "a", PerMinute(1), start.Add(time.Second)); res.Allowed {
+		t.Error("Expected second request to be rejected")
+	}
+	if n := s.Len(); n != 2 {
+		t.Errorf("Expected 2 buckets, got %d", n)
+	}
+	// Both buckets have refilled, so are evicted by the next sweep.
+	if res, _ := s.Take(ctx, "c", PerSecond(1), start.Add(2*time.Minute)); !res.Allowed {
+		t.Error("Expected request to be allowed")
+	}
+	if n := s.Len(); n != 1 {
+		t.Errorf("Expected 1 bucket after eviction, got %d", n)
+	}
+}
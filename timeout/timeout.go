@@ -0,0 +1,251 @@
+// Package timeout provides a middleware which limits the duration of
+// requests.
+//
+// Unlike http.TimeoutHandler, the response is not buffered. The handler
+// writes directly to the client until the deadline, after which its writes
+// are discarded. If nothing has been written by then, a 503 (or 504) error is
+// served by way of httperr.Respond, so that the error template of the view
+// middleware applies when the timeout middleware comes after it in the
+// middleware stack:
+//
+//  r.Use(view.New(viewConf))
+//  r.Use(timeout.New(timeout.Config{Timeout: 5 * time.Second}))
+//
+// Handlers should stop work when the request context is cancelled, as they
+// continue to run after the deadline. For this reason, the handler is given
+// its own copy of the view stash, if any, which replaces the original only if
+// the handler returns in time, so that the error template is never rendered
+// from a stash which an abandoned handler may still modify. When the timeout
+// middleware comes before the view middleware instead, the view skips
+// rendering once the request context is cancelled.
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flimzy/juniper/donewriter"
+	"github.com/flimzy/juniper/httperr"
+	"github.com/flimzy/juniper/view"
+)
+
+// Config configures the timeout middleware.
+type Config struct {
+	// Timeout is the maximum duration of a request.
+	Timeout time.Duration
+	// Status is the status code served when the timeout expires. If zero, 503
+	// (service unavailable) is used. 504 (gateway timeout) is appropriate for
+	// proxies.
+	Status int
+}
+
+// New returns a new timeout middleware. New panics if c.Timeout is not
+// positive.
+func New(c Config) func(http.Handler) http.Handler {
+	if c.Timeout <= 0 {
+		panic("timeout: timeout must be positive")
+	}
+	status := c.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			w, ok := rw.(donewriter.DoneWriter)
+			if !ok {
+				w = donewriter.New(rw)
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), c.Timeout)
+			defer cancel()
+			tw := newTimeoutWriter(ctx, w)
+			req := r.WithContext(ctx)
+			stash := view.GetStash(r)
+			var handlerStash view.Stash
+			if stash != nil {
+				handlerStash = copyStash(stash)
+				req = view.WithStash(req, handlerStash)
+			}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, req)
+				close(done)
+			}()
+			var finished bool
+			select {
+			case p := <-panicked:
+				tw.stop()
+				panic(p)
+			case <-done:
+				finished = true
+			case <-ctx.Done():
+			}
+			if finished && stash != nil {
+				// The handler has returned, so its stash is no longer shared.
+				for key := range stash {
+					delete(stash, key)
+				}
+				for key, value := range handlerStash {
+					stash[key] = value
+				}
+			}
+			if tw.finish(finished) {
+				return
+			}
+			err := ctx.Err()
+			if err == context.DeadlineExceeded {
+				err = httperr.Wrapf(status, err, "request timed out")
+			}
+			httperr.Respond(w, r, err)
+		})
+	}
+}
+
+func copyStash(stash view.Stash) view.Stash {
+	c := make(view.Stash, len(stash))
+	for key, value := range stash {
+		c[key] = value
+	}
+	return c
+}
+
+// timeoutWriter passes writes through to the underlying writer until its
+// context is done, or it is stopped, after which writes are discarded. The
+// handler has its own header map, which is copied to the underlying writer
+// when the response is started, so that it may be modified safely after the
+// timeout.
+type timeoutWriter struct {
+	ctx    context.Context
+	w      donewriter.DoneWriter
+	header http.Header
+
+	mu      sync.Mutex
+	stopped bool
+	started bool
+}
+
+var _ donewriter.DoneWriter = &timeoutWriter{}
+var _ http.Flusher = &timeoutWriter{}
+
+func newTimeoutWriter(ctx context.Context, w donewriter.DoneWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ctx:    ctx,
+		w:      w,
+		header: w.Header().Clone(),
+	}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// Done returns true if a response has been written. After the timeout, it
+// returns true without consulting the underlying writer, which then belongs
+// to the timeout middleware, so that late errors are only logged.
+func (tw *timeoutWriter) Done() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.isStopped() || tw.started || tw.w.Done()
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.isStopped() || tw.started {
+		return
+	}
+	tw.copyHeader()
+	tw.started = true
+	tw.w.WriteHeader(status)
+}
+
+// Write writes b to the underlying writer. After the timeout, it returns
+// http.ErrHandlerTimeout.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.isStopped() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.started {
+		tw.copyHeader()
+		tw.started = true
+	}
+	return tw.w.Write(b)
+}
+
+// Flush flushes buffered data to the client, if the underlying writer
+// supports it. After the timeout, Flush does nothing.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	f, ok := tw.w.(http.Flusher)
+	if !ok || tw.isStopped() {
+		return
+	}
+	if !tw.started {
+		tw.copyHeader()
+		tw.started = true
+	}
+	f.Flush()
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// copyHeader replaces the underlying writer's headers with the handler's.
+// tw.mu must be held.
+func (tw *timeoutWriter) copyHeader() {
+	dst := tw.w.Header()
+	for key := range dst {
+		if _, ok := tw.header[key]; !ok {
+			delete(dst, key)
+		}
+	}
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+}
+
+// isStopped returns true if writes are to be discarded. tw.mu must be held.
+func (tw *timeoutWriter) isStopped() bool {
+	return tw.stopped || tw.ctx.Err() != nil
+}
+
+// finish stops tw, once the handler has returned, or the context is done. It
+// returns true if the response is complete: if the handler returned before
+// the context was done, or returned after starting a response. Otherwise, an
+// error response is due. If the handler returned in time without starting a
+// response, any headers it set are applied.
+func (tw *timeoutWriter) finish(returned bool) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.stopped = true
+	if !returned {
+		return false
+	}
+	if tw.started {
+		return true
+	}
+	if tw.ctx.Err() != nil {
+		return false
+	}
+	tw.copyHeader()
+	return true
+}
+
+// stop discards subsequent writes by the handler.
+func (tw *timeoutWriter) stop() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.stopped = true
+}
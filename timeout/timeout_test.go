@@ -0,0 +1,244 @@
+package timeout
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flimzy/diff"
+
+	"github.com/flimzy/juniper/httperr"
+	"github.com/flimzy/juniper/view"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    Config
+		handler http.HandlerFunc
+		status  int
+		header  http.Header
+		body    string
+	}{
+		{
+			name: "fast handler",
+			conf: Config{Timeout: time.Second},
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("X-Test", "foo")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("created"))
+			},
+			status: http.StatusCreated,
+			header: http.Header{"X-Test": {"foo"}},
+			body:   "created",
+		},
+		{
+			name: "headers without body",
+			conf: Config{Timeout: time.Second},
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("X-Test", "foo")
+			},
+			status: http.StatusOK,
+			header: http.Header{"X-Test": {"foo"}},
+		},
+		{
+			name: "flush",
+			conf: Config{Timeout: time.Second},
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("X-Test", "foo")
+				w.(http.Flusher).Flush()
+				w.Header().Set("X-Test", "bar")
+			},
+			status: http.StatusOK,
+			header: http.Header{"X-Test": {"foo"}},
+		},
+		{
+			name: "slow handler",
+			conf: Config{Timeout: 10 * time.Millisecond},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				w.Header().Set("X-Test", "late")
+				if _, err := w.Write([]byte("late")); err != http.ErrHandlerTimeout {
+					t.Errorf("Unexpected write error: %v", err)
+				}
+			},
+			status: http.StatusServiceUnavailable,
+			header: http.Header{},
+			body:   "Error 503: request timed out: context deadline exceeded",
+		},
+		{
+			name: "gateway timeout",
+			conf: Config{Timeout: 10 * time.Millisecond, Status: http.StatusGatewayTimeout},
+			handler: func(_ http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			status: http.StatusGatewayTimeout,
+			header: http.Header{},
+			body:   "Error 504: request timed out: context deadline exceeded",
+		},
+		{
+			name: "response started",
+			conf: Config{Timeout: 10 * time.Millisecond},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("partial"))
+				<-r.Context().Done()
+				_, _ = w.Write([]byte(" late"))
+			},
+			status: http.StatusOK,
+			header: http.Header{},
+			body:   "partial",
+		},
+		{
+			name: "late flush",
+			conf: Config{Timeout: 10 * time.Millisecond},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				w.(http.Flusher).Flush()
+			},
+			status: http.StatusServiceUnavailable,
+			header: http.Header{},
+			body:   "Error 503: request timed out: context deadline exceeded",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlerDone := make(chan struct{})
+			handler := New(test.conf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(handlerDone)
+				test.handler(w, r)
+			}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			<-handlerDone
+			res := w.Result()
+			if res.StatusCode != test.status {
+				t.Errorf("Unexpected status: %d", res.StatusCode)
+			}
+			res.Header.Del("Content-Type")
+			if d := diff.Interface(test.header, res.Header); d != nil {
+				t.Error(d)
+			}
+			body, _ := ioutil.ReadAll(res.Body)
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	handler := New(Config{Timeout: time.Second})(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		panic("oops")
+	}))
+	defer func() {
+		if r := recover(); r != "oops" {
+			t.Errorf("Unexpected panic: %v", r)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestTimeoutView(t *testing.T) {
+	conf := view.Config{TemplateDir: "../view/test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"}
+	tests := []struct {
+		name       string
+		viewInside bool
+		status     int
+		body       string
+	}{
+		{
+			name:   "view outside",
+			status: http.StatusServiceUnavailable,
+			body:   "Error 503: request timed out: context deadline exceeded",
+		},
+		{
+			name:       "view inside",
+			viewInside: true,
+			status:     http.StatusServiceUnavailable,
+			body:       "Error 503: request timed out: context deadline exceeded",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlerDone := make(chan struct{})
+			var handler http.Handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			})
+			if test.viewInside {
+				handler = view.New(conf)(handler)
+			}
+			inner := handler
+			handler = New(Config{Timeout: 10 * time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(handlerDone)
+				inner.ServeHTTP(w, r)
+			}))
+			if !test.viewInside {
+				handler = view.New(conf)(handler)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			// Wait for the handler goroutine to finish, so that any late
+			// rendering would be detected.
+			<-handlerDone
+			if w.Code != test.status {
+				t.Errorf("Unexpected status: %d", w.Code)
+			}
+			if d := diff.Text(test.body, w.Body.String()); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestTimeoutStash(t *testing.T) {
+	conf := view.Config{TemplateDir: "../view/test", DefaultTemplate: "hello.tmpl", ErrorTemplate: "error.tmpl"}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		body    string
+	}{
+		{
+			name: "in time",
+			handler: func(_ http.ResponseWriter, r *http.Request) {
+				view.GetStash(r)["Name"] = "Bob"
+			},
+			status: http.StatusOK,
+			body:   "Hello, Bob!",
+		},
+		{
+			name: "after deadline",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				stash := view.GetStash(r)
+				for i := 0; i < 100; i++ {
+					stash["Name"] = i
+				}
+				httperr.Respond(w, r, errors.New("late error"))
+			},
+			status: http.StatusServiceUnavailable,
+			body:   "Error 503: request timed out: context deadline exceeded",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlerDone := make(chan struct{})
+			handler := view.New(conf)(New(Config{Timeout: 10 * time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(handlerDone)
+				test.handler(w, r)
+			})))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			<-handlerDone
+			if w.Code != test.status {
+				t.Errorf("Unexpected status: %d", w.Code)
+			}
+			if d := diff.Text(test.body, w.Body.String()); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -16,6 +16,25 @@ const (
 	// StashKeyEntryPoint is used to override the default template entry point
 	// for the given request.
 	StashKeyEntryPoint = "_entryPoint"
+	// StashKeyHeaders is a stash key which, if set to an http.Header, defines
+	// headers to be added to the response just before rendering. A header set
+	// this way replaces any value already set for the same key.
+	StashKeyHeaders = "_headers"
+	// StashKeyCookies is a stash key which, if set to a []*http.Cookie, defines
+	// cookies to be set on the response just before rendering.
+	StashKeyCookies = "_cookies"
+	// StashKeyError is a stash key used to store the client-safe message of
+	// the error returned by an httperr.HandlerFunc, for use by the error
+	// template. See httperr.ClientMessage.
+	StashKeyError = "_error"
+	// StashKeyCorrelationID is a stash key used to store the correlation ID
+	// of an error whose message is hidden in production mode, or an empty
+	// string, for use by the error template.
+	StashKeyCorrelationID = "_correlationID"
+	// StashKeyValidation is a stash key used to store the
+	// *httperr.ValidationError returned by an httperr.HandlerFunc, so that
+	// the request's template may render inline field errors.
+	StashKeyValidation = "_validation"
 )
 
 const (
@@ -27,10 +27,46 @@ func GetStash(r *http.Request) Stash {
 	return stash
 }
 
+// renderContextKey is a context key used to fetch the render state from a
+// context. The returned value is of type *renderState
+var renderContextKey = &contextKey{"render"}
+
+// renderState tracks whether the response for a request has been rendered by
+// a view middleware, so that nested view middlewares render only once.
+type renderState struct {
+	rendered bool
+}
+
 // setStash creates an empty stash, and stores it in the passed request's
-// context, then returns the request with the new context.
+// context, then returns the request with the new context. If the request
+// already has a stash, as is the case for nested view middlewares, the request
+// is returned unaltered, so that the existing stash is shared.
 func setStash(r *http.Request) *http.Request {
-	stash := Stash(make(map[string]interface{}))
+	if GetStash(r) != nil {
+		return r
+	}
+	return WithStash(r, nil)
+}
+
+// WithStash returns a copy of r whose context carries stash. A view middleware
+// serving the returned request uses stash instead of creating a new one, which
+// allows handlers to be tested with a pre-populated stash. If stash is nil, an
+// empty stash is used.
+func WithStash(r *http.Request, stash Stash) *http.Request {
+	if stash == nil {
+		stash = Stash(make(map[string]interface{}))
+	}
 	ctx := context.WithValue(r.Context(), stashContextKey, stash)
 	return r.WithContext(ctx)
 }
+
+// setRenderState returns the render state stored in the request's context,
+// creating and storing a new one if none exists yet.
+func setRenderState(r *http.Request) (*http.Request, *renderState) {
+	if state, ok := r.Context().Value(renderContextKey).(*renderState); ok {
+		return r, state
+	}
+	state := &renderState{}
+	ctx := context.WithValue(r.Context(), renderContextKey, state)
+	return r.WithContext(ctx), state
+}
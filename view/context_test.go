@@ -54,3 +54,37 @@ func TestSetStash(t *testing.T) {
 		t.Fatal("Stash not set")
 	}
 }
+
+func TestSetStashExisting(t *testing.T) {
+	req := stashRequest("GET", "/", nil, map[string]interface{}{"foo": "bar"})
+	req2 := setStash(req)
+	expected := Stash{"foo": "bar"}
+	if d := diff.Interface(expected, GetStash(req2)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestSetRenderState(t *testing.T) {
+	req, state := setRenderState(httptest.NewRequest("GET", "/", nil))
+	state.rendered = true
+	_, state2 := setRenderState(req)
+	if state2 != state {
+		t.Error("Render state not reused")
+	}
+}
+
+func TestWithStash(t *testing.T) {
+	t.Run("nil stash", func(t *testing.T) {
+		req := WithStash(httptest.NewRequest("GET", "/", nil), nil)
+		if stash := GetStash(req); stash == nil {
+			t.Fatal("Stash not set")
+		}
+	})
+	t.Run("stash", func(t *testing.T) {
+		stash := Stash{"foo": "bar"}
+		req := WithStash(httptest.NewRequest("GET", "/", nil), stash)
+		if d := diff.Interface(stash, GetStash(req)); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+// Package loader parses template files and their include paths, so that the
+// view and mail packages resolve templates and includes the same way.
+package loader
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Parser wraps the parsing methods of a text or HTML template.
+type Parser struct {
+	ParseFiles func(filenames ...string) error
+	ParseGlob  func(pattern string) error
+}
+
+// Load parses the template file name from dir, followed by the files in each
+// include path whose names end in suffix. An include path which does not
+// exist is an error. An include path with no matching files is skipped.
+func Load(p Parser, dir, name string, includes []string, suffix string) error {
+	if dir == "" {
+		return errors.New("template dir not defined")
+	}
+	if err := p.ParseFiles(dir + "/" + name); err != nil {
+		return errors.Wrapf(err, "failed to parse template %q", name)
+	}
+	for _, libPath := range includes {
+		if _, err := os.Stat(libPath); err != nil {
+			return errors.Wrapf(err, "failed to parse include path '%s'", libPath)
+		}
+		pattern := libPath + "/*" + suffix
+		if matches, _ := filepath.Glob(pattern); len(matches) == 0 {
+			continue
+		}
+		if err := p.ParseGlob(pattern); err != nil {
+			return errors.Wrapf(err, "failed to parse include path '%s'", libPath)
+		}
+	}
+	return nil
+}
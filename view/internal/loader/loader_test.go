@@ -0,0 +1,63 @@
+package loader
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/flimzy/testy"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		includes []string
+		suffix   string
+		expected []string
+		err      string
+	}{
+		{
+			name:     "no includes",
+			expected: []string{"test.tmpl"},
+		},
+		{
+			name:     "include",
+			includes: []string{"../../test/lib"},
+			expected: []string{"test.tmpl", "base.tmpl"},
+		},
+		{
+			name:     "no matching includes",
+			includes: []string{"../../test/lib"},
+			suffix:   ".txt.tmpl",
+			expected: []string{"test.tmpl"},
+		},
+		{
+			name:     "missing include path",
+			includes: []string{"../../test/missing"},
+			err:      "failed to parse include path '../../test/missing': stat ../../test/missing: no such file or directory",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl := template.New("")
+			err := Load(Parser{
+				ParseFiles: func(filenames ...string) error {
+					_, err := tmpl.ParseFiles(filenames...)
+					return err
+				},
+				ParseGlob: func(pattern string) error {
+					_, err := tmpl.ParseGlob(pattern)
+					return err
+				},
+			}, "../../test", "test.tmpl", test.includes, test.suffix)
+			testy.Error(t, test.err, err)
+			if n := len(tmpl.Templates()); n != len(test.expected) {
+				t.Errorf("Expected %d templates, got %d", len(test.expected), n)
+			}
+			for _, name := range test.expected {
+				if tmpl.Lookup(name) == nil {
+					t.Errorf("Template %q not defined", name)
+				}
+			}
+		})
+	}
+}
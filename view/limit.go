@@ -0,0 +1,54 @@
+package view
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/flimzy/juniper/httperr"
+)
+
+// ErrOutputTooLarge is returned when rendered output exceeds the configured
+// MaxOutputBytes.
+var ErrOutputTooLarge = httperr.New(http.StatusInternalServerError, "template output exceeds maximum size")
+
+// limitWriter is an io.Writer which fails once more than max bytes have been
+// written, or once ctx is done. Template execution stops at the first failed
+// write.
+type limitWriter struct {
+	w   io.Writer
+	ctx context.Context
+	max int64
+	n   int64
+}
+
+var _ io.Writer = &limitWriter{}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, httperr.Wrap(http.StatusServiceUnavailable, errors.Wrap(err, "template execution aborted"))
+	}
+	if w.max > 0 && w.n+int64(len(p)) > w.max {
+		return 0, ErrOutputTooLarge
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// context returns a context derived from parent, limited by the configured
+// timeout, if any.
+func (v *view) context(parent context.Context) (context.Context, context.CancelFunc) {
+	if v.timeout > 0 {
+		return context.WithTimeout(parent, v.timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// limit wraps w to enforce the configured output limit, and the cancellation
+// of ctx.
+func (v *view) limit(ctx context.Context, w io.Writer) io.Writer {
+	return &limitWriter{w: w, ctx: ctx, max: v.maxBytes}
+}
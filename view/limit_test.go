@@ -0,0 +1,69 @@
+package view
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/flimzy/diff"
+	"github.com/flimzy/testy"
+)
+
+func TestLimitWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		max      int64
+		writes   []string
+		expected string
+		err      string
+	}{
+		{
+			name:     "unlimited",
+			ctx:      context.Background(),
+			writes:   []string{"foo", "bar"},
+			expected: "foobar",
+		},
+		{
+			name:     "within limit",
+			ctx:      context.Background(),
+			max:      6,
+			writes:   []string{"foo", "bar"},
+			expected: "foobar",
+		},
+		{
+			name:     "limit exceeded",
+			ctx:      context.Background(),
+			max:      5,
+			writes:   []string{"foo", "bar"},
+			expected: "foo",
+			err:      "template output exceeds maximum size",
+		},
+		{
+			name: "cancelled",
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			}(),
+			writes: []string{"foo"},
+			err:    "template execution aborted: context canceled",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := (&view{maxBytes: test.max}).limit(test.ctx, buf)
+			var err error
+			for _, s := range test.writes {
+				if _, err = w.Write([]byte(s)); err != nil {
+					break
+				}
+			}
+			if d := diff.Text(test.expected, buf.String()); d != nil {
+				t.Error(d)
+			}
+			testy.Error(t, test.err, err)
+		})
+	}
+}
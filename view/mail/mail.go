@@ -0,0 +1,172 @@
+// Package mail renders multipart email messages from paired text and HTML
+// templates, using the same configuration as the view middleware.
+//
+// For a message named "welcome", the template welcome.txt.tmpl is rendered with
+// text/template, and welcome.html.tmpl with html/template, both found in the
+// configured TemplateDir. Either may be omitted, but not both. Includes are
+// resolved as by the view middleware, except that within each include path,
+// files ending in .txt.tmpl are parsed with the text template, and files
+// ending in .html.tmpl with the HTML template.
+//
+// The subject is taken from the "subject" template, if it is defined by the
+// text template, or else by the HTML template:
+//
+//  {{ define "subject" }}Welcome, {{ .Name }}!{{ end }}
+//  Hello {{ .Name }}, and welcome aboard.
+package mail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/flimzy/juniper/view"
+	"github.com/flimzy/juniper/view/internal/loader"
+)
+
+const (
+	// SubjectTemplate is the name of the template from which the message
+	// subject is rendered.
+	SubjectTemplate = "subject"
+	// TextSuffix is the file name suffix of plain text templates.
+	TextSuffix = ".txt.tmpl"
+	// HTMLSuffix is the file name suffix of HTML templates.
+	HTMLSuffix = ".html.tmpl"
+)
+
+// Renderer renders email messages.
+type Renderer struct {
+	templateDir string
+	includes    []string
+	funcMap     map[string]interface{}
+}
+
+// NewRenderer returns a new Renderer. The TemplateDir, Includes and FuncMaps
+// fields of c are used as they are by the view middleware. Other fields are
+// ignored.
+func NewRenderer(c view.Config) *Renderer {
+	funcMap := make(map[string]interface{})
+	for _, fm := range c.FuncMaps {
+		for k, v := range fm {
+			funcMap[k] = v
+		}
+	}
+	return &Renderer{
+		templateDir: c.TemplateDir,
+		includes:    c.Includes,
+		funcMap:     funcMap,
+	}
+}
+
+// executor is the subset of methods shared by text and HTML templates.
+type executor interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// Render renders the message name with data, and returns the result. The
+// returned message has no sender or recipients set.
+func (r *Renderer) Render(name string, data view.Stash) (*Message, error) {
+	if r.templateDir == "" {
+		return nil, errors.New("template dir not defined")
+	}
+	text, err := r.textTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	html, err := r.htmlTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	if text == nil && html == nil {
+		return nil, errors.Errorf("no templates found for message %q", name)
+	}
+	msg := &Message{}
+	if text != nil {
+		if msg.Text, err = execute(text, name+TextSuffix, data); err != nil {
+			return nil, err
+		}
+		if text.Lookup(SubjectTemplate) != nil {
+			if msg.Subject, err = execute(text, SubjectTemplate, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if html != nil {
+		if msg.HTML, err = execute(html, name+HTMLSuffix, data); err != nil {
+			return nil, err
+		}
+		if msg.Subject == "" && html.Lookup(SubjectTemplate) != nil {
+			if msg.Subject, err = execute(html, SubjectTemplate, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	msg.Subject = strings.TrimSpace(msg.Subject)
+	return msg, nil
+}
+
+func execute(tmpl executor, name string, data view.Stash) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(buf, name, data); err != nil {
+		return "", errors.Wrapf(err, "failed to execute template %q", name)
+	}
+	return buf.String(), nil
+}
+
+// textTemplate parses the text template for the message name. If the template
+// does not exist, nil is returned.
+func (r *Renderer) textTemplate(name string) (*texttemplate.Template, error) {
+	if !r.exists(name + TextSuffix) {
+		return nil, nil
+	}
+	t := texttemplate.New("").Funcs(r.funcMap)
+	err := loader.Load(loader.Parser{
+		ParseFiles: func(filenames ...string) error {
+			_, err := t.ParseFiles(filenames...)
+			return err
+		},
+		ParseGlob: func(pattern string) error {
+			_, err := t.ParseGlob(pattern)
+			return err
+		},
+	}, r.templateDir, name+TextSuffix, r.includes, TextSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// htmlTemplate parses the HTML template for the message name. If the template
+// does not exist, nil is returned.
+func (r *Renderer) htmlTemplate(name string) (*htmltemplate.Template, error) {
+	if !r.exists(name + HTMLSuffix) {
+		return nil, nil
+	}
+	t := htmltemplate.New("").Funcs(r.funcMap)
+	err := loader.Load(loader.Parser{
+		ParseFiles: func(filenames ...string) error {
+			_, err := t.ParseFiles(filenames...)
+			return err
+		},
+		ParseGlob: func(pattern string) error {
+			_, err := t.ParseGlob(pattern)
+			return err
+		},
+	}, r.templateDir, name+HTMLSuffix, r.includes, HTMLSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// exists returns true unless the template file name is known not to exist.
+func (r *Renderer) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(r.templateDir, name))
+	return !os.IsNotExist(err)
+}
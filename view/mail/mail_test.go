@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/flimzy/diff"
+	"github.com/flimzy/testy"
+
+	"github.com/flimzy/juniper/view"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     view.Config
+		msgName  string
+		data     view.Stash
+		expected *Message
+		err      string
+	}{
+		{
+			name: "no template dir",
+			err:  "template dir not defined",
+		},
+		{
+			name:    "no templates",
+			conf:    view.Config{TemplateDir: "testdata"},
+			msgName: "oink",
+			err:     `no templates found for message "oink"`,
+		},
+		{
+			name:    "text and html",
+			conf:    view.Config{TemplateDir: "testdata", Includes: []string{"testdata/lib"}},
+			msgName: "welcome",
+			data:    view.Stash{"Name": "<Bob>"},
+			expected: &Message{
+				Subject: "Welcome, <Bob>!",
+				Text:    "Hello <Bob>, and welcome aboard.\n-- \nThe Team",
+				HTML:    `<p>Hello &lt;Bob&gt;, and <em>welcome</em> aboard.</p><img src="cid:logo"><footer>The Team</footer>`,
+			},
+		},
+		{
+			name: "html only",
+			conf: view.Config{TemplateDir: "testdata",
+				FuncMaps: []template.FuncMap{{"upper": strings.ToUpper}},
+			},
+			msgName: "reset",
+			data:    view.Stash{"URL": "https://example.com/reset?token=abc"},
+			expected: &Message{
+				Subject: "Reset YOUR password",
+				HTML:    `<a href="https://example.com/reset?token=abc">Reset</a>`,
+			},
+		},
+		{
+			name:    "execution error",
+			conf:    view.Config{TemplateDir: "testdata"},
+			msgName: "broken",
+			data:    view.Stash{"Foo": 1},
+			err:     `failed to execute template "broken.txt.tmpl": template: broken.txt.tmpl:1:7: executing "broken.txt.tmpl" at <.Foo.Bar>: can't evaluate field Bar in type interface {}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := NewRenderer(test.conf).Render(test.msgName, test.data)
+			testy.Error(t, test.err, err)
+			if d := diff.Interface(test.expected, msg); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
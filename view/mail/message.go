@@ -0,0 +1,210 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Image is an image embedded in the HTML part of a message. HTML templates
+// refer to it by its content ID:
+//
+//  <img src="cid:logo">
+type Image struct {
+	// ContentID identifies the image within the message.
+	ContentID string
+	// ContentType is the MIME type of the image, such as "image/png".
+	ContentType string
+	// Filename is an optional file name for the image.
+	Filename string
+	// Data is the image content.
+	Data []byte
+}
+
+// Message is a rendered email message.
+type Message struct {
+	// From and To are RFC 5322 addresses, such as "bob@example.com" or
+	// "Bob <bob@example.com>". Invalid addresses cause the message to be
+	// rejected when it is written.
+	From    string
+	To      []string
+	Subject string
+	// Text is the plain text body.
+	Text string
+	// HTML is the HTML body.
+	HTML string
+	// Images are embedded inline in the HTML body. They are ignored if HTML is
+	// empty.
+	Images []Image
+}
+
+// Bytes returns the message as a MIME multipart/alternative message, suitable
+// for passing to net/smtp.SendMail.
+func (m *Message) Bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if _, err := m.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// countWriter counts the bytes written to the underlying writer.
+type countWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// WriteTo writes the message to w as a MIME multipart/alternative message.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{Writer: w}
+	err := m.write(cw)
+	return cw.n, err
+}
+
+func (m *Message) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	var header []string
+	if m.From != "" {
+		from, err := formatAddresses(m.From)
+		if err != nil {
+			return err
+		}
+		header = append(header, "From: "+from)
+	}
+	if len(m.To) > 0 {
+		to, err := formatAddresses(m.To...)
+		if err != nil {
+			return err
+		}
+		header = append(header, "To: "+to)
+	}
+	if m.Subject != "" {
+		header = append(header, "Subject: "+mime.QEncoding.Encode("utf-8", m.Subject))
+	}
+	header = append(header,
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary="+mw.Boundary(),
+	)
+	if _, err := io.WriteString(w, strings.Join(header, "\r\n")+"\r\n\r\n"); err != nil {
+		return err
+	}
+	if m.Text != "" {
+		if err := writeText(mw, "text/plain; charset=utf-8", m.Text); err != nil {
+			return err
+		}
+	}
+	if m.HTML != "" {
+		if err := m.writeHTML(mw); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// formatAddresses parses addrs, and formats them for an address header, with
+// display names encoded as needed. Parsing rejects malformed addresses,
+// including those containing line breaks, which could otherwise be used to
+// inject additional headers.
+func formatAddresses(addrs ...string) (string, error) {
+	formatted := make([]string, len(addrs))
+	for i, addr := range addrs {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid address %q", addr)
+		}
+		if a.Name == "" {
+			formatted[i] = a.Address
+		} else {
+			formatted[i] = a.String()
+		}
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// writeHTML writes the HTML body to mw, as a multipart/related part if there
+// are any images.
+func (m *Message) writeHTML(mw *multipart.Writer) error {
+	if len(m.Images) == 0 {
+		return writeText(mw, "text/html; charset=utf-8", m.HTML)
+	}
+	boundary := multipart.NewWriter(ioutil.Discard).Boundary()
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/related; boundary=" + boundary},
+	})
+	if err != nil {
+		return err
+	}
+	related := multipart.NewWriter(part)
+	if err := related.SetBoundary(boundary); err != nil {
+		return err
+	}
+	if err := writeText(related, "text/html; charset=utf-8", m.HTML); err != nil {
+		return err
+	}
+	for _, img := range m.Images {
+		if err := writeImage(related, img); err != nil {
+			return err
+		}
+	}
+	return related.Close()
+}
+
+// writeImage writes img to mw as a base64-encoded inline part.
+func writeImage(mw *multipart.Writer, img Image) error {
+	disposition := "inline"
+	if img.Filename != "" {
+		disposition = mime.FormatMediaType("inline", map[string]string{"filename": img.Filename})
+	}
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {img.ContentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Id":                {"<" + img.ContentID + ">"},
+		"Content-Disposition":       {disposition},
+	})
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(img.Data)
+	for len(encoded) > 0 {
+		n := 76
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		if _, err := io.WriteString(part, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// writeText writes body to mw as a quoted-printable part.
+func writeText(mw *multipart.Writer, contentType, body string) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,189 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/flimzy/diff"
+	"github.com/flimzy/testy"
+)
+
+// part is a decoded MIME part, for comparison in tests.
+type part struct {
+	ContentType string
+	ContentID   string
+	Body        string
+	Parts       []part
+}
+
+func readParts(t *testing.T, contentType string, body []byte) []part {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil
+	}
+	var parts []part
+	r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+			if data, err = base64.StdEncoding.DecodeString(strings.Replace(string(data), "\r\n", "", -1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ct := p.Header.Get("Content-Type")
+		result := part{ContentType: ct, ContentID: p.Header.Get("Content-Id")}
+		if sub := readParts(t, ct, data); sub != nil {
+			result.Parts = sub
+		} else {
+			result.Body = string(data)
+		}
+		parts = append(parts, result)
+	}
+	return parts
+}
+
+func TestMessageBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    *Message
+		header map[string]string
+		parts  []part
+		err    string
+	}{
+		{
+			name: "text and html",
+			msg: &Message{
+				From:    "team@example.com",
+				To:      []string{"bob@example.com", "alice@example.com"},
+				Subject: "Welcome, Bob!",
+				Text:    "Hello Bob.",
+				HTML:    "<p>Hello Bob.</p>",
+			},
+			header: map[string]string{
+				"From":         "team@example.com",
+				"To":           "bob@example.com, alice@example.com",
+				"Subject":      "Welcome, Bob!",
+				"Mime-Version": "1.0",
+			},
+			parts: []part{
+				{ContentType: "text/plain; charset=utf-8", Body: "Hello Bob."},
+				{ContentType: "text/html; charset=utf-8", Body: "<p>Hello Bob.</p>"},
+			},
+		},
+		{
+			name: "display names",
+			msg: &Message{
+				From: "Jürgen <team@example.com>",
+				To:   []string{"Bob Smith <bob@example.com>"},
+				Text: "Hello Bob.",
+			},
+			header: map[string]string{
+				"From":         "=?utf-8?q?J=C3=BCrgen?= <team@example.com>",
+				"To":           `"Bob Smith" <bob@example.com>`,
+				"Mime-Version": "1.0",
+			},
+			parts: []part{
+				{ContentType: "text/plain; charset=utf-8", Body: "Hello Bob."},
+			},
+		},
+		{
+			name: "header injection",
+			msg: &Message{
+				To:   []string{"b@example.com\r\nBcc: evil@example.com"},
+				Text: "Hello Bob.",
+			},
+			err: `invalid address "b@example.com\r\nBcc: evil@example.com": mail: expected single address, got "\r\nBcc: evil@example.com"`,
+		},
+		{
+			name: "invalid sender",
+			msg: &Message{
+				From: "not an address",
+				Text: "Hello Bob.",
+			},
+			err: `invalid address "not an address": mail: no angle-addr`,
+		},
+		{
+			name: "encoded subject",
+			msg: &Message{
+				Subject: "Grüße",
+				Text:    "Grüße",
+			},
+			header: map[string]string{
+				"Subject":      "=?utf-8?q?Gr=C3=BC=C3=9Fe?=",
+				"Mime-Version": "1.0",
+			},
+			parts: []part{
+				{ContentType: "text/plain; charset=utf-8", Body: "Grüße"},
+			},
+		},
+		{
+			name: "inline images",
+			msg: &Message{
+				Text: "Hello Bob.",
+				HTML: `<img src="cid:logo">`,
+				Images: []Image{
+					{ContentID: "logo", ContentType: "image/png", Filename: "logo.png", Data: []byte("not really a png")},
+				},
+			},
+			header: map[string]string{
+				"Mime-Version": "1.0",
+			},
+			parts: []part{
+				{ContentType: "text/plain; charset=utf-8", Body: "Hello Bob."},
+				{Parts: []part{
+					{ContentType: "text/html; charset=utf-8", Body: `<img src="cid:logo">`},
+					{ContentType: "image/png", ContentID: "<logo>", Body: "not really a png"},
+				}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.msg.Bytes()
+			testy.Error(t, test.err, err)
+			m, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := make(map[string]string)
+			for key := range m.Header {
+				if key != "Content-Type" {
+					header[key] = m.Header.Get(key)
+				}
+			}
+			if d := diff.Interface(test.header, header); d != nil {
+				t.Error(d)
+			}
+			body, err := ioutil.ReadAll(m.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts := readParts(t, m.Header.Get("Content-Type"), body)
+			for i := range parts {
+				// Ignore the randomly generated boundary
+				if parts[i].Parts != nil {
+					parts[i].ContentType = ""
+				}
+			}
+			if d := diff.Interface(test.parts, parts); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
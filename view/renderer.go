@@ -0,0 +1,51 @@
+package view
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// Renderer renders view templates outside of an HTTP request, for instance to
+// produce email bodies or documents from the same templates and includes that
+// are served by the view middleware.
+type Renderer struct {
+	v *view
+}
+
+// NewRenderer returns a new Renderer, which loads and executes templates the
+// same way as a View middleware created with the same configuration.
+func NewRenderer(c Config) *Renderer {
+	return &Renderer{v: newView(c)}
+}
+
+// Render renders the template name to w, with data as the stash. As with the
+// View middleware, data may contain StashKeyFuncMap and StashKeyEntryPoint
+// values to override the configured funcs and entry point. Render returns
+// immediately if ctx is already cancelled.
+//
+// The MaxOutputBytes and Timeout limits are enforced as for the View
+// middleware, except that output is not buffered, so w may have received
+// partial output when execution is aborted.
+func (r *Renderer) Render(ctx context.Context, w io.Writer, name string, data Stash) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := r.v.getTemplate(nil, name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := r.v.context(ctx)
+	defer cancel()
+	return r.v.execute(r.v.limit(ctx, w), tmpl, name, data)
+}
+
+// RenderString renders the template name with data as the stash, and returns
+// the result as a string.
+func (r *Renderer) RenderString(ctx context.Context, name string, data Stash) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := r.Render(ctx, buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,91 @@
+package view
+
+import (
+	"context"
+	"html/template"
+	"testing"
+
+	"github.com/flimzy/diff"
+	"github.com/flimzy/testy"
+)
+
+func TestRendererRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     Config
+		ctx      context.Context
+		tmplName string
+		data     Stash
+		expected string
+		err      string
+	}{
+		{
+			name: "cancelled context",
+			conf: Config{TemplateDir: "test"},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			}(),
+			tmplName: "test.tmpl",
+			err:      "context canceled",
+		},
+		{
+			name:     "missing template",
+			conf:     Config{TemplateDir: "test"},
+			tmplName: "oink",
+			err:      `failed to parse template "oink": open test/oink: no such file or directory`,
+		},
+		{
+			name:     "with data",
+			conf:     Config{TemplateDir: "test"},
+			tmplName: "hello.tmpl",
+			data:     Stash{"Name": "Bob"},
+			expected: "Hello, Bob!\n",
+		},
+		{
+			name:     "no data",
+			conf:     Config{TemplateDir: "test"},
+			tmplName: "test.tmpl",
+			expected: "Test template\n",
+		},
+		{
+			name: "funcs",
+			conf: Config{TemplateDir: "test",
+				FuncMaps: []template.FuncMap{{"foo": func() string { return "foo!" }}},
+			},
+			tmplName: "foo.tmpl",
+			expected: "Foo? foo!\n",
+		},
+		{
+			name: "stash funcs",
+			conf: Config{TemplateDir: "test",
+				FuncMaps: []template.FuncMap{{"foo": func() string { return "foo!" }}},
+			},
+			tmplName: "foo.tmpl",
+			data: Stash{StashKeyFuncMap: template.FuncMap{
+				"foo": func() string { return "no foo :(" },
+			}},
+			expected: "Foo? no foo :(\n",
+		},
+		{
+			name:     "with includes",
+			conf:     Config{TemplateDir: "test", EntryPoint: "base.tmpl", Includes: []string{"test/lib"}},
+			tmplName: "lib.tmpl",
+			expected: "before\n\nincluded\n\nafter\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			result, err := NewRenderer(test.conf).RenderString(ctx, test.tmplName, test.data)
+			testy.Error(t, test.err, err)
+			if d := diff.Text(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -3,14 +3,18 @@
 package view
 
 import (
+	"bytes"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/flimzy/juniper/donewriter"
 	"github.com/flimzy/juniper/httperr"
+	"github.com/flimzy/juniper/view/internal/loader"
 )
 
 type view struct {
@@ -19,6 +23,9 @@ type view struct {
 	defTemplate string
 	funcMap     map[string]interface{}
 	includes    []string
+	maxBytes    int64
+	timeout     time.Duration
+	errTemplate string
 }
 
 type Config struct {
@@ -32,7 +39,8 @@ type Config struct {
 	// or augmented with stash[StashKeyFuncMap]
 	FuncMaps []template.FuncMap
 	// Includes is zero or more paths to include when parsing all templates.
-	// This can be used to define global templates or components
+	// This can be used to define global templates or components. An include
+	// path which does not exist is an error.
 	Includes []string
 	// EntryPoint defines the template that is executed by the
 	// template.ExecuteTemplate call. This will typically be a basic HTML
@@ -40,37 +48,136 @@ type Config struct {
 	// falls back to the template name. This value may be overwridden per request
 	// by the stash[StashKeyEntryPoint] value
 	EntryPoint string
+	// MaxOutputBytes, if positive, limits the size of the rendered output.
+	// Template execution is aborted once the limit is exceeded, and an
+	// ErrOutputTooLarge error response is served instead.
+	MaxOutputBytes int64
+	// Timeout, if positive, limits the time spent executing a template. Once
+	// the timeout elapses, or the request context is cancelled, template
+	// execution is aborted at the next write, and a 503 error response is
+	// served instead.
+	//
+	// When either MaxOutputBytes or Timeout is set, the rendered output is
+	// buffered, so that no partial output is sent when execution is aborted.
+	Timeout time.Duration
+	// ErrorTemplate is the name of the template (to be found in TemplateDir)
+	// used to render errors returned by httperr.HandlerFunc handlers. The
+	// client-safe message of the error is stored in stash[StashKeyError], its
+	// correlation ID, if any, in stash[StashKeyCorrelationID], and its status
+	// code in stash[StashKeyStatus]. If unset, errors are served by
+	// httperr.HandleRequestError.
+	ErrorTemplate string
 }
 
 // New returns a new View middleware instance. It accepts the following arguments:
 //
 // dir:         The root dir where templates are to be found
 // defTemplate:
+//
+// View middlewares may be nested, for instance when mounting a sub-router with
+// its own template configuration. Nested instances share the stash created by
+// the outermost instance, and only the innermost instance renders the response.
+//
+// Errors returned by httperr.HandlerFunc handlers beneath the middleware are
+// rendered with the ErrorTemplate, unless a response has already been sent.
+//
+// Nothing is rendered if the request context has been cancelled, for
+// instance by the timeout middleware, or because the client went away.
 func New(c Config) func(http.Handler) http.Handler {
+	v := newView(c)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			w := donewriter.New(rw)
+			r = setStash(r)
+			r, state := setRenderState(r)
+			r = httperr.WithResponder(r, v.respondError)
+			next.ServeHTTP(w, r)
+			if w.Done() || state.rendered || r.Context().Err() != nil {
+				return
+			}
+			state.rendered = true
+			v.render(w, r)
+		})
+	}
+}
+
+func newView(c Config) *view {
 	funcMap := make(template.FuncMap)
 	for _, fm := range c.FuncMaps {
 		for k, v := range fm {
 			funcMap[k] = v
 		}
 	}
-	v := &view{
+	return &view{
 		templateDir: c.TemplateDir,
 		entryPoint:  c.EntryPoint,
 		defTemplate: c.DefaultTemplate,
 		funcMap:     funcMap,
 		includes:    c.Includes,
+		maxBytes:    c.MaxOutputBytes,
+		timeout:     c.Timeout,
+		errTemplate: c.ErrorTemplate,
 	}
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-			w := donewriter.New(rw)
-			r = setStash(r)
-			next.ServeHTTP(w, r)
-			if w.Done() {
-				return
-			}
-			v.render(w, r)
-		})
+}
+
+// respondError is an httperr.ErrorResponder which arranges for err to be
+// rendered with the error template, once the handler returns. Validation
+// errors are instead rendered with the request's template, so that forms may
+// be re-rendered with inline errors. If the response has already been sent,
+// the error is left to httperr.HandleRequestError, which only logs it. In
+// either case, the error is reported to the request's httperr Reporter. In
+// development mode, the httperr debug page is served immediately for 5xx
+// errors, including the stash contents.
+func (v *view) respondError(w http.ResponseWriter, r *http.Request, err error) {
+	if done, _ := donewriter.WriterIsDone(w); done {
+		_ = httperr.HandleRequestError(w, r, err)
+		return
+	}
+	if httperr.WantsDebugPage(r, err) {
+		_ = httperr.WriteDebugPage(w, r, err, stashSection(r))
+		return
+	}
+	var verr *httperr.ValidationError
+	isValidation := errors.As(err, &verr)
+	if isValidation {
+		if _, e := v.templateName(r); e != nil {
+			isValidation = false
+		}
+	}
+	if v.errTemplate == "" && !isValidation {
+		_ = httperr.HandleRequestError(w, r, err)
+		return
+	}
+	for key, values := range httperr.Headers(err) {
+		w.Header()[key] = values
+	}
+	msg, id := httperr.ClientMessage(w, r, err)
+	httperr.ReportErrorID(r, err, id)
+	stash := GetStash(r)
+	stash[StashKeyError] = msg
+	stash[StashKeyCorrelationID] = id
+	stash[StashKeyStatus] = httperr.GetConfig(r).StatusCode(err)
+	if isValidation {
+		stash[StashKeyValidation] = verr
+		return
+	}
+	stash[StashKeyTemplate] = v.errTemplate
+}
+
+// stashSection returns the stash of r as a section of the httperr debug page.
+func stashSection(r *http.Request) httperr.DebugSection {
+	return httperr.DebugSection{Title: "Stash", Values: GetStash(r)}
+}
+
+// renderError serves err, an error which occurred while rendering the
+// response to r, with the request's httperr Config. If rendering had already
+// started the response, the error is only logged and reported.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	if httperr.WantsDebugPage(r, err) {
+		_ = httperr.WriteDebugPage(w, r, err, stashSection(r))
+		return
 	}
+	_ = httperr.HandleRequestError(w, r, err)
 }
 
 func (v *view) templateName(r *http.Request) (string, error) {
@@ -86,17 +193,64 @@ func (v *view) templateName(r *http.Request) (string, error) {
 func (v *view) render(w http.ResponseWriter, r *http.Request) {
 	tmplName, err := v.templateName(r)
 	if err != nil {
-		httperr.HandleError(w, err)
+		renderError(w, r, err)
 		return
 	}
 	tmpl, err := v.getTemplate(r, tmplName)
 	if err != nil {
-		httperr.HandleError(w, err)
+		renderError(w, r, err)
 		return
 	}
 	stash := GetStash(r)
 	stash[StashKeyRequest] = r
-	funcMap := v.funcMap
+	if v.maxBytes <= 0 && v.timeout <= 0 {
+		writeHeaders(w, stash)
+		writeStatus(w, stash)
+		if e := v.execute(w, tmpl, tmplName, stash); e != nil {
+			log.Printf("Template error: %s", e)
+			renderError(w, r, e)
+		}
+		return
+	}
+	ctx, cancel := v.context(r.Context())
+	defer cancel()
+	buf := &bytes.Buffer{}
+	if e := v.execute(v.limit(ctx, buf), tmpl, tmplName, stash); e != nil {
+		log.Printf("Template error: %s", e)
+		renderError(w, r, e)
+		return
+	}
+	// The stash headers and cookies are only applied once rendering has
+	// succeeded, so that they are not sent along with an error response.
+	writeHeaders(w, stash)
+	writeStatus(w, stash)
+	if _, e := buf.WriteTo(w); e != nil {
+		log.Printf("Failed to write response: %s", e)
+	}
+}
+
+// writeHeaders applies the headers and cookies found in the stash, and the
+// default Content-Type, if none is set.
+func writeHeaders(w http.ResponseWriter, stash Stash) {
+	setHeaders(w, stash)
+	if _, ok := w.Header()["Content-Type"]; !ok {
+		w.Header().Set("Content-Type", DefaultContentType)
+	}
+}
+
+func writeStatus(w http.ResponseWriter, stash Stash) {
+	if status, ok := stash[StashKeyStatus].(int); ok {
+		w.WriteHeader(status)
+	}
+}
+
+// execute executes tmpl with stash as data, applying any funcs and entry point
+// override found in the stash.
+func (v *view) execute(w io.Writer, tmpl *template.Template, tmplName string, stash Stash) error {
+	funcMap := make(template.FuncMap, len(v.funcMap))
+	for key, val := range v.funcMap {
+		funcMap[key] = val
+	}
 	if m, ok := stash[StashKeyFuncMap]; ok {
 		var fm template.FuncMap
 		switch t := m.(type) {
@@ -109,12 +263,6 @@ func (v *view) render(w http.ResponseWriter, r *http.Request) {
 			funcMap[key] = val
 		}
 	}
-	if _, ok := w.Header()["Content-Type"]; !ok {
-		w.Header().Set("Content-Type", DefaultContentType)
-	}
-	if status, ok := stash[StashKeyStatus].(int); ok {
-		w.WriteHeader(status)
-	}
 	entryPoint := v.entryPoint
 	if ep, ok := stash[StashKeyEntryPoint].(string); ok {
 		entryPoint = ep
@@ -122,27 +270,49 @@ func (v *view) render(w http.ResponseWriter, r *http.Request) {
 	if entryPoint == "" {
 		entryPoint = tmplName
 	}
+	return tmpl.Funcs(funcMap).ExecuteTemplate(w, entryPoint, stash)
+}
 
-	if e := tmpl.Funcs(funcMap).ExecuteTemplate(w, entryPoint, stash); e != nil {
-		log.Printf("Template error: %s", e)
-		httperr.HandleError(w, e)
-		return
+// setHeaders applies the header and cookie directives found in the stash to w.
+func setHeaders(w http.ResponseWriter, stash Stash) {
+	var headers http.Header
+	switch t := stash[StashKeyHeaders].(type) {
+	case http.Header:
+		headers = t
+	case map[string][]string:
+		headers = t
+	}
+	for key, values := range headers {
+		key = http.CanonicalHeaderKey(key)
+		w.Header().Del(key)
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	if cookies, ok := stash[StashKeyCookies].([]*http.Cookie); ok {
+		for _, cookie := range cookies {
+			http.SetCookie(w, cookie)
+		}
 	}
 }
 
 func (v *view) getTemplate(r *http.Request, name string) (*template.Template, error) {
-	if v.templateDir == "" {
-		return nil, errors.New("template dir not defined")
-	}
 	t := template.New("")
 	t.Funcs(v.funcMap)
-	if _, err := t.ParseFiles(v.templateDir + "/" + name); err != nil {
-		return nil, errors.Wrapf(err, "failed to parse template %q", name)
-	}
-	for _, libPath := range v.includes {
-		if _, err := t.ParseGlob(libPath + "/*"); err != nil {
-			return nil, errors.Wrapf(err, "failed to parse include path '%s'", libPath)
-		}
+	err := loader.Load(loader.Parser{
+		ParseFiles: func(filenames ...string) error {
+			_, err := t.ParseFiles(filenames...)
+			return err
+		},
+		ParseGlob: func(pattern string) error {
+			_, err := t.ParseGlob(pattern)
+			return err
+		},
+	}, v.templateDir, name, v.includes, "")
+	if err != nil {
+		// A missing or unreadable template is a server error, regardless of
+		// how the underlying error would otherwise be classified.
+		return nil, httperr.Wrap(http.StatusInternalServerError, err)
 	}
 	return t, nil
 }
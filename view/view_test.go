@@ -1,14 +1,20 @@
 package view
 
 import (
+	"errors"
 	"html/template"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/flimzy/diff"
 	"github.com/flimzy/testy"
+
+	"github.com/flimzy/juniper/httperr"
 )
 
 func TestGetTemplate(t *testing.T) {
@@ -247,6 +253,175 @@ func TestMiddleware(t *testing.T) {
 			},
 			body: "Test template",
 		},
+		{
+			name: "stash headers",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl"},
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Foo", "bar")
+				w.Header().Set("X-Bar", "baz")
+				stash := GetStash(r)
+				stash[StashKeyHeaders] = http.Header{
+					"Content-Type": []string{"text/plain"},
+					"X-Foo":        []string{"qux", "quux"},
+				}
+			}),
+			status: http.StatusOK,
+			header: http.Header{
+				"Content-Type": []string{"text/plain"},
+				"X-Foo":        []string{"qux", "quux"},
+				"X-Bar":        []string{"baz"},
+			},
+			body: "Test template",
+		},
+		{
+			name: "stash cookies",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl"},
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				stash := GetStash(r)
+				stash[StashKeyCookies] = []*http.Cookie{
+					{Name: "foo", Value: "bar"},
+					{Name: "baz", Value: "qux", Path: "/"},
+				}
+			}),
+			status: http.StatusOK,
+			header: http.Header{
+				"Content-Type": []string{DefaultContentType},
+				"Set-Cookie":   []string{"foo=bar", "baz=qux; Path=/"},
+			},
+			body: "Test template",
+		},
+		{
+			name: "output within limit",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", MaxOutputBytes: 100},
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				GetStash(r)[StashKeyStatus] = http.StatusCreated
+			}),
+			status: http.StatusCreated,
+			body:   "Test template",
+		},
+		{
+			name: "missing template",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "nope.tmpl"},
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				// Do nothing
+			}),
+			status: http.StatusInternalServerError,
+			body:   `Error 500: failed to parse template "nope.tmpl": open test/nope.tmpl: no such file or directory`,
+		},
+		{
+			name: "output too large",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", MaxOutputBytes: 5},
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				GetStash(r)[StashKeyStatus] = http.StatusCreated
+				GetStash(r)[StashKeyHeaders] = http.Header{"X-Test": {"foo"}}
+				GetStash(r)[StashKeyCookies] = []*http.Cookie{{Name: "session", Value: "abc"}}
+			}),
+			status: http.StatusInternalServerError,
+			header: http.Header{},
+			body:   "Error 500: template output exceeds maximum size",
+		},
+		{
+			name: "timeout",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "slow.tmpl", Timeout: 5 * time.Millisecond,
+				FuncMaps: []template.FuncMap{{"sleep": func() string {
+					time.Sleep(10 * time.Millisecond)
+					return ""
+				}}},
+			},
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				GetStash(r)["Items"] = []string{"a", "b", "c"}
+			}),
+			status: http.StatusServiceUnavailable,
+			body:   "Error 503: template execution aborted: context deadline exceeded",
+		},
+		{
+			name: "returned error, error template",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"},
+			handler: httperr.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) error {
+				GetStash(r)[StashKeyTemplate] = "hello.tmpl"
+				return httperr.New(http.StatusNotFound, "no such user")
+			}),
+			status: http.StatusNotFound,
+			body:   "Error 404: no such user",
+		},
+		{
+			name: "returned error, error template, production",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"},
+			req: httperr.WithConfig(httptest.NewRequest(http.MethodGet, "/", nil), &httperr.Config{
+				Production:       true,
+				ErrorLog:         log.New(ioutil.Discard, "", 0),
+				NewCorrelationID: func() string { return "abc123" },
+			}),
+			handler: httperr.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+				return errors.New("db password rejected")
+			}),
+			status: http.StatusInternalServerError,
+			body:   "Error 500: Internal Server Error (correlation ID: abc123)",
+		},
+		{
+			name: "returned error, error template, public message",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"},
+			handler: httperr.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+				return httperr.New(http.StatusConflict, "duplicate key user_email_idx", httperr.WithPublicMessage("Email already registered"))
+			}),
+			status: http.StatusConflict,
+			body:   "Error 409: Email already registered",
+		},
+		{
+			name: "returned error with headers, error template",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"},
+			handler: httperr.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+				return httperr.MethodNotAllowed(http.MethodGet, http.MethodHead)
+			}),
+			status: http.StatusMethodNotAllowed,
+			header: http.Header{
+				"Allow":        []string{"GET, HEAD"},
+				"Content-Type": []string{DefaultContentType},
+			},
+			body: "Error 405: Method Not Allowed",
+		},
+		{
+			name: "returned error, no error template",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl"},
+			handler: httperr.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+				return httperr.New(http.StatusForbidden, "go away")
+			}),
+			status: http.StatusForbidden,
+			body:   "Error 403: go away",
+		},
+		{
+			name: "returned validation error",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"},
+			handler: httperr.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) error {
+				GetStash(r)[StashKeyTemplate] = "form.tmpl"
+				verr := &httperr.ValidationError{}
+				return verr.Add("email", "required", "Email is required").Err()
+			}),
+			status: http.StatusUnprocessableEntity,
+			body:   `<input name="email"><span>Email is required</span>`,
+		},
+		{
+			name: "returned error, already written",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"},
+			handler: httperr.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) error {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("partial"))
+				return errors.New("too late")
+			}),
+			status: http.StatusAccepted,
+			body:   "partial",
+		},
+		{
+			name: "recovered panic, error template",
+			conf: Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"},
+			handler: httperr.Recover(func(_ *http.Request, _ *httperr.PanicError) {})(
+				http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+					panic("oh no")
+				}),
+			),
+			status: http.StatusInternalServerError,
+			body:   "Error 500: panic: oh no",
+		},
 		{
 			name: "funcMaps",
 			conf: Config{TemplateDir: "test", DefaultTemplate: "foo.tmpl",
@@ -289,3 +464,189 @@ func TestMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestNestedMiddleware(t *testing.T) {
+	outer := New(Config{TemplateDir: "test", DefaultTemplate: "test.tmpl"})
+	inner := New(Config{TemplateDir: "test/nested", DefaultTemplate: "nested.tmpl"})
+	setValue := func(key, value string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				GetStash(r)[key] = value
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	sub := http.NewServeMux()
+	sub.Handle("/nested", inner(setValue("inner", "Inner")(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))))
+	sub.Handle("/written", inner(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	mux := http.NewServeMux()
+	mux.Handle("/sub/", http.StripPrefix("/sub", sub))
+	mux.Handle("/", http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	handler := outer(setValue("outer", "Outer")(mux))
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "outer only",
+			path:   "/",
+			status: http.StatusOK,
+			body:   "Test template",
+		},
+		{
+			name:   "nested",
+			path:   "/sub/nested",
+			status: http.StatusOK,
+			body:   "Outer Inner",
+		},
+		{
+			name:   "nested already written",
+			path:   "/sub/written",
+			status: http.StatusNoContent,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			res := w.Result()
+			defer res.Body.Close()
+
+			if res.StatusCode != test.status {
+				t.Errorf("Unexpected status code: %d", res.StatusCode)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestErrorReporting(t *testing.T) {
+	reporter := &httperr.MemoryReporter{}
+	defer func(r httperr.Reporter) {
+		httperr.DefaultReporter = r
+	}(httperr.DefaultReporter)
+	httperr.DefaultReporter = reporter
+
+	mw := New(Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"})
+	handler := mw(httperr.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) error {
+		return httperr.New(http.StatusNotFound, "no such user")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	reports := reporter.Reports()
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reports))
+	}
+	if rep := reports[0]; rep.Status != http.StatusNotFound || rep.URL != "/users/1" {
+		t.Errorf("Unexpected report: %d %s", rep.Status, rep.URL)
+	}
+}
+
+func TestDebugPage(t *testing.T) {
+	defer func(d bool) {
+		httperr.Development = d
+	}(httperr.Development)
+	httperr.Development = true
+
+	mw := New(Config{TemplateDir: "test", DefaultTemplate: "test.tmpl", ErrorTemplate: "error.tmpl"})
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{
+			name: "returned error",
+			handler: httperr.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) error {
+				GetStash(r)["user"] = "bob"
+				return errors.New("db down")
+			}),
+		},
+		{
+			name: "render error",
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				GetStash(r)["user"] = "bob"
+				GetStash(r)[StashKeyEntryPoint] = "missing"
+			}),
+		},
+		{
+			name: "missing template",
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				GetStash(r)["user"] = "bob"
+				GetStash(r)[StashKeyTemplate] = "nope.tmpl"
+			}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", "text/html")
+			mw(test.handler).ServeHTTP(w, r)
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("Unexpected status: %d", w.Code)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, "<h2>Stash</h2>") || !strings.Contains(body, "<tr><td>user</td><td>&#34;bob&#34;</td></tr>") {
+				t.Errorf("Expected stash in debug page, got:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestDebugPageStarted(t *testing.T) {
+	defer func(d bool, l *log.Logger) {
+		httperr.Development, httperr.ErrorLog = d, l
+	}(httperr.Development, httperr.ErrorLog)
+	httperr.Development = true
+	httperr.ErrorLog = log.New(ioutil.Discard, "", 0)
+
+	mw := New(Config{TemplateDir: "test", DefaultTemplate: "fail.tmpl",
+		FuncMaps: []template.FuncMap{{"fail": func() (string, error) {
+			return "", errors.New("boom")
+		}}},
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html")
+	mw(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Unexpected status: %d", w.Code)
+	}
+	if d := diff.Text("partial", w.Body.String()); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestRenderErrorConfig(t *testing.T) {
+	reporter := &httperr.MemoryReporter{}
+	c := &httperr.Config{
+		Production:       true,
+		ErrorLog:         log.New(ioutil.Discard, "", 0),
+		NewCorrelationID: func() string { return "abc123" },
+		Reporter:         reporter,
+	}
+	handler := c.Middleware(New(Config{TemplateDir: "test", DefaultTemplate: "oink.tmpl"})(
+		http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}),
+	))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected status: %d", w.Code)
+	}
+	if d := diff.Text("Error 500: Internal Server Error (correlation ID: abc123)", w.Body.String()); d != nil {
+		t.Error(d)
+	}
+	if reports := reporter.Reports(); len(reports) != 1 || reports[0].CorrelationID != "abc123" {
+		t.Errorf("Unexpected reports: %v", reports)
+	}
+}
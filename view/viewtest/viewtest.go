@@ -0,0 +1,95 @@
+// Package viewtest provides utilities for testing templates, and handlers which
+// communicate with the view middleware by way of the stash.
+//
+//  func TestHello(t *testing.T) {
+//      conf := view.Config{TemplateDir: "templates", DefaultTemplate: "hello.tmpl"}
+//      req := viewtest.NewRequest("GET", "/", nil, view.Stash{"User": "bob"})
+//      res, stash := viewtest.Serve(conf, helloHandler, req)
+//      if stash["Name"] != "Bob" {
+//          t.Errorf("Unexpected name: %v", stash["Name"])
+//      }
+//      body, _ := ioutil.ReadAll(res.Body)
+//      viewtest.Golden(t, "hello", body)
+//  }
+//
+// Golden files are read from, and when the VIEWTEST_UPDATE environment
+// variable is set to a non-empty value, written to, the testdata directory of
+// the package under test:
+//
+//  VIEWTEST_UPDATE=1 go test ./...
+package viewtest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flimzy/juniper/view"
+)
+
+// UpdateEnv is the environment variable which, when set to a non-empty value,
+// causes Golden to write golden files instead of comparing against them. An
+// environment variable is used rather than a command line flag, so that
+// packages which define their own -update flag may still import viewtest.
+const UpdateEnv = "VIEWTEST_UPDATE"
+
+// NewRequest returns a new incoming server request, suitable for passing to a
+// handler, with stash pre-populated in its context. If stash is nil, an empty
+// stash is used.
+func NewRequest(method, target string, body io.Reader, stash view.Stash) *http.Request {
+	return view.WithStash(httptest.NewRequest(method, target, body), stash)
+}
+
+// Serve serves req with handler, wrapped by a view middleware configured with
+// conf. It returns the recorded response, and the stash as it was left after
+// the handler and the view middleware ran. If req does not already contain a
+// stash, an empty one is added.
+func Serve(conf view.Config, handler http.Handler, req *http.Request) (*http.Response, view.Stash) {
+	stash := view.GetStash(req)
+	if stash == nil {
+		req = view.WithStash(req, nil)
+		stash = view.GetStash(req)
+	}
+	w := httptest.NewRecorder()
+	view.New(conf)(handler).ServeHTTP(w, req)
+	return w.Result(), stash
+}
+
+// Render renders the template name, as configured by conf, with data as the
+// stash, and returns the rendered output.
+func Render(conf view.Config, name string, data view.Stash) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := view.NewRenderer(conf).Render(context.Background(), buf, name, data)
+	return buf.Bytes(), err
+}
+
+// Golden compares actual against the contents of the golden file
+// testdata/<name>.golden, and reports a test error if they differ. When the
+// UpdateEnv environment variable is set, the golden file is written with
+// actual instead.
+func Golden(t testing.TB, name string, actual []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %s", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Output does not match golden file %s.\nExpected:\n%s\nActual:\n%s", path, expected, actual)
+	}
+}
@@ -0,0 +1,136 @@
+package viewtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flimzy/diff"
+	"github.com/flimzy/testy"
+
+	"github.com/flimzy/juniper/view"
+)
+
+func TestNewRequest(t *testing.T) {
+	req := NewRequest("GET", "/", nil, view.Stash{"foo": "bar"})
+	expected := view.Stash{"foo": "bar"}
+	if d := diff.Interface(expected, view.GetStash(req)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		req     *http.Request
+		status  int
+		body    string
+		stash   view.Stash
+	}{
+		{
+			name:    "no stash",
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {}),
+			req:     NewRequest("GET", "/", nil, nil),
+			status:  http.StatusOK,
+			body:    "Test template",
+			stash:   view.Stash{},
+		},
+		{
+			name: "handler sets stash",
+			handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				stash := view.GetStash(r)
+				stash["Name"] = stash["User"]
+				stash[view.StashKeyTemplate] = "hello.tmpl"
+				stash[view.StashKeyStatus] = http.StatusCreated
+			}),
+			req:    NewRequest("GET", "/", nil, view.Stash{"User": "Bob"}),
+			status: http.StatusCreated,
+			body:   "Hello, Bob!",
+			stash: view.Stash{
+				"User":                "Bob",
+				"Name":                "Bob",
+				view.StashKeyTemplate: "hello.tmpl",
+				view.StashKeyStatus:   http.StatusCreated,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := view.Config{TemplateDir: "../test", DefaultTemplate: "test.tmpl"}
+			res, stash := Serve(conf, test.handler, test.req)
+			defer res.Body.Close()
+			if res.StatusCode != test.status {
+				t.Errorf("Unexpected status: %d", res.StatusCode)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := diff.Text(test.body, string(body)); d != nil {
+				t.Error(d)
+			}
+			delete(stash, view.StashKeyRequest)
+			if d := diff.Interface(test.stash, stash); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmplName string
+		data     view.Stash
+		expected string
+		err      string
+	}{
+		{
+			name:     "success",
+			tmplName: "hello.tmpl",
+			data:     view.Stash{"Name": "Bob"},
+			expected: "Hello, Bob!\n",
+		},
+		{
+			name:     "missing template",
+			tmplName: "oink.tmpl",
+			err:      `failed to parse template "oink.tmpl": open ../test/oink.tmpl: no such file or directory`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := view.Config{TemplateDir: "../test"}
+			result, err := Render(conf, test.tmplName, test.data)
+			testy.Error(t, test.err, err)
+			if d := diff.Text(test.expected, string(result)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestGolden(t *testing.T) {
+	conf := view.Config{TemplateDir: "../test"}
+	result, err := Render(conf, "hello.tmpl", view.Stash{"Name": "Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Golden(t, "hello", result)
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	t.Setenv(UpdateEnv, "1")
+	path := filepath.Join("testdata", "update.golden")
+	defer func() { _ = os.Remove(path) }()
+	Golden(t, "update", []byte("updated"))
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.Text("updated", string(content)); d != nil {
+		t.Error(d)
+	}
+}